@@ -13,36 +13,81 @@ import (
 const PATH_VERSION = "./VERSION.txt"
 const CSV_FILE_NAME = "president_poll.csv"
 const INTERNET_FILE = "https://www.electoral-vote.com/evp2024/Pres/pres_polls.txt"
+const CURRENT_CYCLE = 2024 // Election year of the current cycle
+const EV_TO_WIN = 270      // Electoral College votes needed to win
 
 var DummyTime = time.Date(1776, time.July, 4, 23, 59, 59, 0, time.UTC)
 
 // Definition of the singleton global.
 type GlobalsStruct struct {
-	Battleground     []string  // List of battleground states
-	CfgFile          string    // Configuration file path
-	DateThreshold    time.Time // No polls used in reports nor plots before this date
-	DbDriver         string    // Database driver name
-	DbFile           string    // Database file name + extension
-	DirCsv           string    // CSV input directory (before database load)
-	DirDatabase      string    // Database directory path
-	DirPlots         string    // Plots directory path
-	DirTemp          string    // Temporary holding area directory path
-	ECVAlgorithm     int       // Cfg: ECV distribution algorithm
-	FlagBattleground bool      // Only report on battleground states (-r ec)? true/false
-	FlagFetch        bool      // Fetch new data from the internet? true/false
-	FlagLoad         bool      // Load new data into the database? true/false
-	FlagPlot         bool      // Plots requested? true/false
-	FlagReport       bool      // Report requested? true/false
-	InternetCsvFile  string    // INTERNET_PREFIX + CSV_FILE_NAME + ".txt"
-	LocalCsvFile     string    // CSV file name + extension
-	PlotHeight       float64   // Height of plot canvase in dots
-	PlotWidth        float64   // Width of plot canvase in dots
-	PollHistoryLimit int       // Limit of how many polls are entertained
-	StateTableFile   string    // State table file path
-	StronglyDem      []string  // List of strongly Democratic states
-	StronglyGop      []string  // List of strongly GOP states
-	TossupThreshold  float64   // Cfg: Threshold of difference below which a tossup can be inferred
-	Version          string    // Software version string
+	BacktestCycle    int         // Cfg: Backtest: election year of the prior cycle
+	BacktestDays     []int       // Cfg: Backtest: run the pipeline as of each of these days before election day
+	BacktestElection time.Time   // Cfg: Backtest: election day of the prior cycle
+	BacktestLimits   []int       // Cfg: Backtest: PollHistoryLimit settings to score
+	BacktestResults  string      // Cfg: Backtest: certified results file path (turnout table format)
+	BacktestTossups  []float64   // Cfg: Backtest: TossupThreshold settings to score
+	Battleground     []string    // List of battleground states
+	BootstrapSamples int         // Cfg: Number of bootstrap resamples per state (0 = no confidence intervals)
+	BootstrapSeed    int64       // Cfg: Bootstrap random generator seed
+	BootstrapTossup  bool        // Cfg: Is a state a tossup when its bootstrap margin interval includes 0?
+	CfgFile          string      // Configuration file path
+	CoverageMinPolls int         // Cfg: Coverage report: flag battleground states with fewer polls than this
+	Cycle            int         // Election year of the poll data being fetched, loaded, and reported (--cycle)
+	ConfidenceLevel  float64     // Cfg: Confidence level for significance tests (E.g. ECVAlgorithm margin-of-error)
+	DateThreshold    time.Time   // No polls used in reports nor plots before this date
+	DbDriver         string      // Database driver name
+	DbFile           string      // Database file name + extension
+	DefSampleSize    int         // Cfg: Sample size assumed for polls that do not report one
+	DiffDate1        time.Time   // Diff report: first as-of date (default: yesterday)
+	DiffDate2        time.Time   // Diff report: second as-of date (default: today)
+	DiffMarginDelta  float64     // Cfg: Diff report: show states whose margin moved more than this many points
+	DirCsv           string      // CSV input directory (before database load)
+	DirDatabase      string      // Database directory path
+	DirPlots         string      // Plots directory path
+	DirTemp          string      // Temporary holding area directory path
+	ECVAlgorithm     string      // Cfg: ECV distribution algorithm name
+	FilterFrom       time.Time   // State report: no polls ending before this date (DummyTime = use DateThreshold)
+	FilterMinSample  int         // State report: minimum poll sample size (0 = no minimum)
+	FilterPollster   string      // State report: pollster name substring ("" = all pollsters)
+	FilterTo         time.Time   // State report: no polls ending after this date (DummyTime = no limit)
+	FlagAll          bool        // State report: ignore PollHistoryLimit? true/false
+	FlagBattleground bool        // Only report on battleground states (-r ec)? true/false
+	FlagByLoad       bool        // Diff report: as-of dates refer to load dates rather than poll end dates? true/false
+	FlagFetch        bool        // Fetch new data from the internet? true/false
+	FlagHouseAdjust  bool        // Subtract pollster house effects before averaging? true/false
+	FlagLoad         bool        // Load new data into the database? true/false
+	FlagPlot         bool        // Plots requested? true/false
+	FlagReport       bool        // Report requested? true/false
+	GroupFilter      []string    // Only report on and plot these states (-g); nil = all states
+	Groups           []Group_t   // Cfg: User-defined state groups, in name order
+	HouseEffectMin   int         // Cfg: Minimum number of polls for a pollster's house effect to be subtracted
+	HouseEffectWin   int         // Cfg: House effect reference window in days (+/-)
+	InternetCsvFile  string      // INTERNET_PREFIX + CSV_FILE_NAME + ".txt"
+	KalmanDrift      float64     // Cfg: Kalman smoother: daily drift (standard deviation) of the true percentage in points
+	LocalCsvFile     string      // CSV file name + extension
+	LoessSpan        int         // Cfg: LOESS smoother: span in days
+	MapDates         []time.Time // EC map: also draw one map as of each of these dates (--map-dates)
+	PlotDPI          int         // Cfg: Resolution of raster plots (png, jpg, tif) in dots per inch
+	PlotFileName     string      // Cfg: Plot file name template (without extension): {name}, {date}, {cycle}
+	PlotFormats      []string    // Cfg: Plot file formats (file extensions); --plot-formats overrides
+	PlotHeight       float64     // Height of plot canvase in dots
+	PlotWidth        float64     // Width of plot canvase in dots
+	PollHistoryLimit int         // Limit of how many polls are entertained
+	PollsterSort     string      // Pollster report: sort key (--sort)
+	PriorFallback    string      // Cfg: Fallback for states without polls: "none", "prior", or "swing"
+	ScenarioFile     string      // What-if scenario file path for -r ec ("" = none)
+	SmoothedAverage  bool        // Cfg: Use the smoothed estimate as the current average in reports? true/false
+	Smoother         string      // Cfg: Poll smoother: "none", "loess", or "kalman"
+	StaleAction      string      // Cfg: What to do with a stale state's call: "mark" or "lean"
+	StaleDays        int         // Cfg: A state whose newest poll is older than this many days is stale (0 = never)
+	StateTableFile   string      // State table file path
+	StronglyDem      []string    // List of strongly Democratic states
+	StronglyGop      []string    // List of strongly GOP states
+	TossupThreshold  float64     // Cfg: Threshold of difference below which a tossup can be inferred
+	TrendHalfLife    float64     // Cfg: Trend regression weight half-life in days
+	TrendWindow      int         // Cfg: Trend regression window in days before the most recent poll
+	TurnoutTableFile string      // Turnout table file path
+	Version          string      // Software version string
 }
 
 // Here's the singleton.
@@ -59,23 +104,38 @@ func InitGlobals() *GlobalsStruct {
 	versionString := string(versionBytes[:])
 	versionString = strings.TrimSpace(versionString)
 
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
 	global = GlobalsStruct{
 		CfgFile:          "config.yaml",
+		Cycle:            CURRENT_CYCLE,
 		DateThreshold:    DummyTime,
 		DbFile:           "ppolls2024.db",
 		DbDriver:         "sqlite",
 		DirCsv:           "./csv/",
 		DirDatabase:      "./database/",
 		DirPlots:         "./plots/",
+		DiffDate1:        today.AddDate(0, 0, -1),
+		DiffDate2:        today,
 		DirTemp:          "./temp/",
+		FilterFrom:       DummyTime,
+		FilterMinSample:  0,
+		FilterPollster:   "",
+		FilterTo:         DummyTime,
+		FlagAll:          false,
 		FlagFetch:        false,
+		FlagHouseAdjust:  false,
 		FlagLoad:         false,
 		FlagReport:       false,
 		FlagPlot:         false,
 		FlagBattleground: false,
+		FlagByLoad:       false,
+		PollsterSort:     "polls",
 		InternetCsvFile:  INTERNET_FILE,
 		LocalCsvFile:     CSV_FILE_NAME,
+		ScenarioFile:     "",
 		StateTableFile:   "state_table.txt",
+		TurnoutTableFile: "turnout_table.txt",
 		Version:          versionString,
 	}
 
@@ -100,7 +160,16 @@ func InitGlobals() *GlobalsStruct {
 		if err != nil {
 			log.Fatalf("InitGlobals: strconv.Atoi(Votes) failed on line %d, reason: %s\n", lineCount, err.Error())
 		}
-		StateTable = append(StateTable, StateTableEntry_t{Stcode: triplet[0], Votes: votesValue, Category: triplet[2]})
+		parent := ""
+		if strings.Contains(triplet[0], "-") { // congressional district
+			parts := strings.Split(triplet[0], "-")
+			_, err = strconv.Atoi(parts[len(parts)-1])
+			if len(parts) != 2 || err != nil {
+				log.Fatalf("InitGlobals: State table line %d has an invalid district code: %s\n", lineCount, triplet[0])
+			}
+			parent = parts[0]
+		}
+		StateTable = append(StateTable, StateTableEntry_t{Stcode: triplet[0], Votes: votesValue, Category: triplet[2], Parent: parent})
 		switch triplet[2] {
 		case "B":
 			global.Battleground = append(global.Battleground, triplet[0])
@@ -116,9 +185,78 @@ func InitGlobals() *GlobalsStruct {
 	log.Printf("InitGlobals: Strongly Democrat states: %v (%d)\n", global.StronglyDem, len(global.StronglyDem))
 	log.Printf("InitGlobals: Strongly GOP states: %v (%d)\n", global.StronglyGop, len(global.StronglyGop))
 
+	loadTurnoutTable()
+
 	return &global
 }
 
+// Load the turnout table: votes cast and percentages per state in the previous election.
+func loadTurnoutTable() {
+	TurnoutTable = ReadTurnoutFile(global.TurnoutTableFile)
+	log.Printf("loadTurnoutTable: %d states\n", len(TurnoutTable))
+}
+
+// ReadTurnoutFile reads a file of "ST Votes Dem Gop" lines (the turnout table format) and returns its entries.
+func ReadTurnoutFile(path string) []TurnoutTableEntry_t {
+	var table []TurnoutTableEntry_t
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("ReadTurnoutFile: os.ReadFile(%s) failed, reason: %s\n", path, err.Error())
+	}
+	lineCount := 0
+	for _, line := range strings.Split(string(bytes), "\n") {
+		lineCount++
+		line = strings.TrimSpace(line)
+		if len(line) < 1 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		quad := strings.Fields(line)
+		if len(quad) != 4 {
+			log.Fatalf("ReadTurnoutFile: %s line %d does not have 4 columns\n", path, lineCount)
+		}
+		for _, entry := range table {
+			if entry.Stcode == quad[0] {
+				log.Fatalf("ReadTurnoutFile: %s state %s on line %d is a duplicate\n", path, quad[0], lineCount)
+			}
+		}
+		votesValue, err := strconv.Atoi(quad[1])
+		if err != nil {
+			log.Fatalf("ReadTurnoutFile: %s strconv.Atoi(Votes) failed on line %d, reason: %s\n", path, lineCount, err.Error())
+		}
+		demValue, err := strconv.ParseFloat(quad[2], 64)
+		if err != nil {
+			log.Fatalf("ReadTurnoutFile: %s strconv.ParseFloat(Dem) failed on line %d, reason: %s\n", path, lineCount, err.Error())
+		}
+		gopValue, err := strconv.ParseFloat(quad[3], 64)
+		if err != nil {
+			log.Fatalf("ReadTurnoutFile: %s strconv.ParseFloat(Gop) failed on line %d, reason: %s\n", path, lineCount, err.Error())
+		}
+		table = append(table, TurnoutTableEntry_t{Stcode: quad[0], Votes: votesValue, PctDem: demValue, PctGop: gopValue})
+	}
+	return table
+}
+
+// TurnoutTableLookup returns a pointer to the turnout table entry of the given state or nil if there is none.
+func TurnoutTableLookup(stcode string) *TurnoutTableEntry_t {
+	for ii := range TurnoutTable {
+		if TurnoutTable[ii].Stcode == stcode {
+			return &TurnoutTable[ii]
+		}
+	}
+	return nil
+}
+
+// SetCycle selects the poll data files of a prior election cycle.
+func SetCycle(year int) {
+	global.Cycle = year
+	if year == CURRENT_CYCLE {
+		return
+	}
+	global.DbFile = fmt.Sprintf("ppolls%d.db", year)
+	global.InternetCsvFile = strings.Replace(INTERNET_FILE, fmt.Sprintf("evp%d", CURRENT_CYCLE), fmt.Sprintf("evp%d", year), 1)
+	global.LocalCsvFile = strings.Replace(CSV_FILE_NAME, ".csv", fmt.Sprintf("_%d.csv", year), 1)
+}
+
 // GetGlobalRef returns a pointer to the singleton instance of GlobalsStruct
 func GetGlobalRef() *GlobalsStruct {
 	return &global
@@ -126,10 +264,28 @@ func GetGlobalRef() *GlobalsStruct {
 
 // State table entry definition
 type StateTableEntry_t struct {
-	Stcode   string // 2-character state code
+	Stcode   string // 2-character state code or congressional district code (state code + "-" + district number)
 	Votes    int    // number of Electoral College Votes
 	Category string // "B" (battleground), "D" (strongly democrat), or "G" (strongly GOP)
+	Parent   string // State code of a congressional district; "" for a state
 }
 
 // State table
 var StateTable = []StateTableEntry_t{}
+
+// Turnout table entry definition
+type TurnoutTableEntry_t struct {
+	Stcode string  // 2-character state code
+	Votes  int     // number of votes cast in the previous election
+	PctDem float64 // Dem percentage in the previous election
+	PctGop float64 // Gop percentage in the previous election
+}
+
+// Turnout table
+var TurnoutTable = []TurnoutTableEntry_t{}
+
+// User-defined state group definition
+type Group_t struct {
+	Name   string   // Group name as given in the configuration file
+	States []string // State codes
+}
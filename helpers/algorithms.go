@@ -0,0 +1,260 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"ppolls2024/global"
+	"strings"
+)
+
+// Input to an ECV award algorithm for one state.
+type AwardInput struct {
+	Votes           int     // Electoral College votes of the state
+	PctDem          float64 // Average Dem percentage
+	PctGop          float64 // Average Gop percentage
+	SampleSizes     []int   // Sample sizes of the averaged polls (0 = unknown)
+	TossupThreshold float64 // Threshold of difference below which a tossup can be inferred
+	ConfidenceLevel float64 // Confidence level of the margin-of-error test (between 0 and 1)
+	DefSampleSize   int     // Sample size assumed for polls that do not report one
+}
+
+// Result of an ECV award algorithm for one state.
+type AwardResult struct {
+	Leader      string // "Dem", "Gop", or "TOSSUP"
+	DemVotes    int    // ECV awarded to Dem
+	GopVotes    int    // ECV awarded to Gop
+	TossupVotes int    // ECV considered a tossup
+	Factor      string // Other-factor indicator shown after the Other trend
+}
+
+/*
+AwardAlgorithm - An ECV award algorithm.
+
+To add an algorithm, implement this interface and add an instance to algorithmRegistry.
+The configuration file ECVAlgorithm parameter refers to an algorithm by Name().
+*/
+type AwardAlgorithm interface {
+	Name() string                         // Short name used in the configuration file
+	Description() string                  // One-line description for --list-algorithms
+	FactorLegend(input AwardInput) string // Report footnote explaining the Factor string for these settings ("" = none)
+	Award(input AwardInput) AwardResult   // Award the state's votes
+}
+
+// Registry of ECV award algorithms in their legacy number order (1, 2, 3, ...).
+var algorithmRegistry = []AwardAlgorithm{
+	splitOtherAlgorithm{},
+	thresholdAlgorithm{},
+	otherTossupAlgorithm{},
+	marginOfErrorAlgorithm{},
+}
+
+// Award all votes to the leader, or to the tossup column.
+func awardTo(leader string, votes int, factor string) AwardResult {
+	switch leader {
+	case "Dem":
+		return AwardResult{Leader: leader, DemVotes: votes, Factor: factor}
+	case "Gop":
+		return AwardResult{Leader: leader, GopVotes: votes, Factor: factor}
+	}
+	return AwardResult{Leader: "TOSSUP", TossupVotes: votes, Factor: factor}
+}
+
+// Translate true | false into "**" | "  ".
+func getFactorString(arg bool) string {
+	if arg {
+		return "**"
+	}
+	return "  "
+}
+
+/*
+Algorithm 1 - split-other.
+
+	Calculate the Other percentage = 100 - the sum of the candidate percentages.
+	Split the "Other" percentage proportionally amongst the candidates.
+	Calculate the difference = absolute value of the difference between the candidates.
+
+	If the difference is below the tossup threshold, then this state is a tossup.
+*/
+type splitOtherAlgorithm struct{}
+
+func (splitOtherAlgorithm) Name() string { return "split-other" }
+
+func (splitOtherAlgorithm) Description() string {
+	return "Split the Other percentage proportionally; tossup if the difference is below TossupThreshold."
+}
+
+func (splitOtherAlgorithm) FactorLegend(AwardInput) string { return "" }
+
+func (splitOtherAlgorithm) Award(input AwardInput) AwardResult {
+	pctOther := CalcOther(input.PctDem, input.PctGop)
+	pctDem := input.PctDem + pctOther*input.PctDem/100.0
+	pctGop := input.PctGop + pctOther*input.PctGop/100.0
+	diff := math.Abs(pctDem - pctGop)
+
+	if diff < input.TossupThreshold {
+		return awardTo("TOSSUP", input.Votes, "")
+	}
+	if pctDem > pctGop {
+		return awardTo("Dem", input.Votes, "")
+	}
+	return awardTo("Gop", input.Votes, "")
+}
+
+/*
+Algorithm 2 - threshold.
+
+	Calculate the Other percentage = 100 - the sum of the candidate percentages.
+	Calculate the difference = absolute value of the difference between the candidates.
+
+	If the "Other" percentage exceeds the difference, then flag this state on return.
+	If the difference is below the tossup threshold, then this state is a tossup.
+*/
+type thresholdAlgorithm struct{}
+
+func (thresholdAlgorithm) Name() string { return "threshold" }
+
+func (thresholdAlgorithm) Description() string {
+	return "Tossup if the difference is below TossupThreshold; flag states where Other exceeds the difference."
+}
+
+func (thresholdAlgorithm) FactorLegend(AwardInput) string {
+	return "** The Other percentage exceeds the difference between Dem and Gop."
+}
+
+func (thresholdAlgorithm) Award(input AwardInput) AwardResult {
+	diff := math.Abs(input.PctDem - input.PctGop)
+	factor := getFactorString(CalcOther(input.PctDem, input.PctGop) > diff)
+	if diff < input.TossupThreshold {
+		return awardTo("TOSSUP", input.Votes, factor)
+	}
+	if input.PctDem > input.PctGop {
+		return awardTo("Dem", input.Votes, factor)
+	}
+	return awardTo("Gop", input.Votes, factor)
+}
+
+/*
+Algorithm 3 - other-tossup.
+
+	Calculate the Other percentage = 100 - the sum of the candidate percentages.
+	Calculate the difference = absolute value of the difference between the candidates.
+
+	If the "Other" percentage exceeds the difference, then this state is a tossup.
+	If the difference is below the tossup threshold, then this state is a tossup.
+*/
+type otherTossupAlgorithm struct{}
+
+func (otherTossupAlgorithm) Name() string { return "other-tossup" }
+
+func (otherTossupAlgorithm) Description() string {
+	return "Tossup if Other exceeds the difference or the difference is below TossupThreshold."
+}
+
+func (otherTossupAlgorithm) FactorLegend(AwardInput) string {
+	return "** The Other percentage exceeds the difference between Dem and Gop."
+}
+
+func (otherTossupAlgorithm) Award(input AwardInput) AwardResult {
+	diff := math.Abs(input.PctDem - input.PctGop)
+	if CalcOther(input.PctDem, input.PctGop) > diff {
+		return awardTo("TOSSUP", input.Votes, getFactorString(true))
+	}
+	if diff < input.TossupThreshold {
+		return awardTo("TOSSUP", input.Votes, " ")
+	}
+	if input.PctDem > input.PctGop {
+		return awardTo("Dem", input.Votes, " ")
+	}
+	return awardTo("Gop", input.Votes, " ")
+}
+
+/*
+Algorithm 4 - margin-of-error.
+
+	Calculate the standard error of the margin (Dem - Gop) for the average of the given polls.
+	Each poll contributes the variance of a multinomial difference, (pDem + pGop - (pDem - pGop)^2) / n,
+	where n is the poll sample size (or DefaultSampleSize if the poll did not report one).
+	The z-score is the absolute margin divided by the standard error.
+
+	If the z-score is below the critical value for the configured confidence level, then this state is a tossup.
+	The z-score is returned as the other-factor string.
+*/
+type marginOfErrorAlgorithm struct{}
+
+func (marginOfErrorAlgorithm) Name() string { return "margin-of-error" }
+
+func (marginOfErrorAlgorithm) Description() string {
+	return "Tossup if the z-score of the margin (from poll sample sizes) is below the ConfidenceLevel critical value."
+}
+
+func (marginOfErrorAlgorithm) FactorLegend(input AwardInput) string {
+	return fmt.Sprintf("The number after the Other trend is the z-score of the margin, at most 99 (tossup below %.2f for %.0f%% confidence).",
+		math.Sqrt2*math.Erfinv(input.ConfidenceLevel), 100.0*input.ConfidenceLevel)
+}
+
+func (marginOfErrorAlgorithm) Award(input AwardInput) AwardResult {
+	sampleSizes := input.SampleSizes
+	if len(sampleSizes) < 1 {
+		sampleSizes = []int{input.DefSampleSize}
+	}
+	pDem := input.PctDem / 100.0
+	pGop := input.PctGop / 100.0
+	variance := 0.0
+	for _, sampleSize := range sampleSizes {
+		if sampleSize < 1 {
+			sampleSize = input.DefSampleSize
+		}
+		variance += (pDem + pGop - (pDem-pGop)*(pDem-pGop)) / float64(sampleSize)
+	}
+	variance /= float64(len(sampleSizes) * len(sampleSizes))
+	stdErr := 100.0 * math.Sqrt(variance)
+	diff := math.Abs(input.PctDem - input.PctGop)
+	zScore := 0.0
+	if stdErr > 0.0 {
+		zScore = diff / stdErr
+	}
+	zCritical := math.Sqrt2 * math.Erfinv(input.ConfidenceLevel)
+	// At most 3 characters, so that the factor column keeps a space after a significant ("*") Other trend.
+	zString := fmt.Sprintf("%3.1f", zScore)
+	if zScore >= 9.95 {
+		zString = fmt.Sprintf("%3.0f", math.Min(zScore, 99.0))
+	}
+	if zScore < zCritical {
+		return awardTo("TOSSUP", input.Votes, zString)
+	}
+	if input.PctDem > input.PctGop {
+		return awardTo("Dem", input.Votes, zString)
+	}
+	return awardTo("Gop", input.Votes, zString)
+}
+
+/*
+LookupAlgorithm - Find a registered algorithm by name (case-insensitive) or by legacy number (1, 2, 3, ...).
+Returns nil if there is no such algorithm.
+*/
+func LookupAlgorithm(name string) AwardAlgorithm {
+	name = strings.TrimSpace(name)
+	for ix, algorithm := range algorithmRegistry {
+		if strings.EqualFold(name, algorithm.Name()) || name == fmt.Sprintf("%d", ix+1) {
+			return algorithm
+		}
+	}
+	return nil
+}
+
+// ListAlgorithms - Show the registered algorithms and then exit to the O/S.
+func ListAlgorithms() {
+	glob := global.GetGlobalRef()
+	fmt.Println("\nECV award algorithms (ECVAlgorithm in the configuration file):")
+	for ix, algorithm := range algorithmRegistry {
+		marker := " "
+		if algorithm.Name() == glob.ECVAlgorithm {
+			marker = "*"
+		}
+		fmt.Printf("%s %d  %-16s  %s\n", marker, ix+1, algorithm.Name(), algorithm.Description())
+	}
+	fmt.Println("\n* = currently configured")
+	os.Exit(0)
+}
@@ -0,0 +1,38 @@
+package helpers
+
+import (
+	"testing"
+)
+
+func TestMarginOfErrorAward(t *testing.T) {
+	tests := []struct {
+		name        string
+		pctDem      float64
+		pctGop      float64
+		sampleSizes []int
+		confidence  float64
+		wantLeader  string
+		wantFactor  string
+	}{
+		{"Dem lead, large sample", 52.0, 44.0, []int{1000}, 0.95, "Dem", "2.6"},
+		{"Dem lead, small sample", 52.0, 44.0, []int{400}, 0.95, "TOSSUP", "1.6"},
+		{"Dem lead at 99%", 52.0, 44.0, []int{900}, 0.99, "TOSSUP", "2.5"},
+		{"Dem lead at 95%", 52.0, 44.0, []int{900}, 0.95, "Dem", "2.5"},
+		{"Gop lead, unknown sizes", 40.0, 55.0, []int{0, 0}, 0.95, "Gop", "5.4"},
+		{"tie, no sizes", 47.0, 47.0, nil, 0.95, "TOSSUP", "0.0"},
+		{"landslide", 90.0, 5.0, []int{2000}, 0.95, "Dem", " 80"},
+		{"capped", 95.0, 2.0, []int{5000, 5000, 5000}, 0.95, "Dem", " 99"},
+	}
+	for _, tt := range tests {
+		got := marginOfErrorAlgorithm{}.Award(AwardInput{Votes: 10, PctDem: tt.pctDem, PctGop: tt.pctGop,
+			SampleSizes: tt.sampleSizes, ConfidenceLevel: tt.confidence, DefSampleSize: 600})
+		if got.Leader != tt.wantLeader || got.Factor != tt.wantFactor {
+			t.Errorf("%s: leader %s factor %q, want %s %q", tt.name, got.Leader, got.Factor, tt.wantLeader, tt.wantFactor)
+		}
+		votes := map[string]int{"Dem": got.DemVotes, "Gop": got.GopVotes, "TOSSUP": got.TossupVotes}
+		if votes[tt.wantLeader] != 10 || got.DemVotes+got.GopVotes+got.TossupVotes != 10 {
+			t.Errorf("%s: votes Dem %d Gop %d Tossup %d, want all 10 to %s", tt.name,
+				got.DemVotes, got.GopVotes, got.TossupVotes, tt.wantLeader)
+		}
+	}
+}
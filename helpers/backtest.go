@@ -0,0 +1,114 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"ppolls2024/global"
+	"strings"
+)
+
+// Score of one backtest run against the certified results.
+type backtestScore struct {
+	correct   int     // States (and districts) whose leader matched the certified winner
+	scored    int     // States (and districts) that have a certified result
+	tossups   int     // Scored states called a tossup
+	demECV    int     // Dem EV called
+	gopECV    int     // Gop EV called
+	evError   int     // Dem EV called - Dem EV certified
+	marginMAE float64 // Mean absolute margin error over the polled states
+	polled    int     // Scored states that had polls
+}
+
+// Score the state results against the certified results.
+func scoreBacktest(results []stateResult, certified []global.TurnoutTableEntry_t) backtestScore {
+	var score backtestScore
+	actualDemECV := 0
+	sumAbsError := 0.0
+	for _, result := range results {
+		var actual *global.TurnoutTableEntry_t
+		for ii := range certified {
+			if certified[ii].Stcode == result.entry.Stcode {
+				actual = &certified[ii]
+				break
+			}
+		}
+		if actual == nil {
+			continue
+		}
+		winner := "Gop"
+		if actual.PctDem > actual.PctGop {
+			winner = "Dem"
+			actualDemECV += result.entry.Votes
+		}
+		score.scored++
+		score.demECV += result.increDem
+		score.gopECV += result.increGop
+		if result.leader == winner {
+			score.correct++
+		}
+		if result.leader == "TOSSUP" {
+			score.tossups++
+		}
+		if result.pollCount > 0 {
+			score.polled++
+			sumAbsError += math.Abs(result.margin() - (actual.PctDem - actual.PctGop))
+		}
+	}
+	score.evError = score.demECV - actualDemECV
+	if score.polled > 0 {
+		score.marginMAE = sumAbsError / float64(score.polled)
+	}
+	return score
+}
+
+/*
+ReportBacktest - Score the averaging and award algorithms against a prior cycle's certified results.
+
+The pipeline is run as of each of BacktestDaysBefore days before BacktestElectionDay, for every registered
+algorithm and every combination of BacktestHistoryLimits and BacktestTossupThresholds.
+DateThreshold does not apply: all of the prior cycle's polls up to the as-of date are eligible.
+PriorFallback does not apply either, because the previous cycle's result would leak the answer.
+With --house-adjust, the house effects are estimated once per as-of date from the polls up to that date only.
+*/
+func ReportBacktest() {
+	glob := global.GetGlobalRef()
+	certified := global.ReadTurnoutFile(glob.BacktestResults)
+	fmt.Printf("\nBacktest of the %d cycle: election day %s, certified results from %s\n",
+		glob.Cycle, glob.BacktestElection.Format("2006-01-02"), glob.BacktestResults)
+	header := "Algorithm         Days  Limit  Tossup  Correct  Tossups  DemEV  GopEV  EVerr  MarginMAE"
+	prtDivider := strings.Repeat("-", len(header))
+	fmt.Println(header)
+	fmt.Println(prtDivider)
+	// Options as of each day before the election, with the house effects (if any) known on that day.
+	asOfOptions := make(map[int]ecOptions)
+	for _, daysBefore := range glob.BacktestDays {
+		opts := currentOptions()
+		opts.asOf = glob.BacktestElection.AddDate(0, 0, -daysBefore)
+		opts.dateThreshold = global.DummyTime
+		opts.priorFallback = "none"
+		asOfOptions[daysBefore] = withHouseEffects(opts)
+	}
+	for _, algorithm := range algorithmRegistry {
+		for _, daysBefore := range glob.BacktestDays {
+			for _, historyLimit := range glob.BacktestLimits {
+				for _, tossupThreshold := range glob.BacktestTossups {
+					opts := asOfOptions[daysBefore]
+					opts.algorithm = algorithm
+					opts.historyLimit = historyLimit
+					opts.tossupThreshold = tossupThreshold
+					score := scoreBacktest(computeEC(opts), certified)
+					fmt.Printf("%-16s  %4d  %5d  %6.2f  %3d/%-3d  %7d  %5d  %5d  %+5d  %9.2f\n",
+						algorithm.Name(), daysBefore, historyLimit, tossupThreshold, score.correct, score.scored,
+						score.tossups, score.demECV, score.gopECV, score.evError, score.marginMAE)
+				}
+			}
+		}
+	}
+	fmt.Println(prtDivider)
+	fmt.Println("Days: days before election day. Limit: PollHistoryLimit. Tossup: TossupThreshold.")
+	if glob.FlagHouseAdjust {
+		fmt.Println("House effects: estimated from the polls up to each as-of date only.")
+	}
+	fmt.Println("Correct: leader matched the certified winner (a tossup is not correct).")
+	fmt.Println("EVerr: Dem EV called - Dem EV certified. MarginMAE: mean absolute (Dem - Gop) error over polled states.")
+}
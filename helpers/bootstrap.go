@@ -0,0 +1,94 @@
+package helpers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"ppolls2024/global"
+	"sort"
+)
+
+// Confidence interval of one average.
+type confInterval struct {
+	low  float64
+	high float64
+}
+
+// Format a confidence interval of a margin as "[ -2.0, +5.3]".
+func (ci confInterval) String() string {
+	return fmt.Sprintf("[%+5.1f,%+5.1f]", ci.low, ci.high)
+}
+
+// Format a confidence interval of a percentage as "[47.0, 50.0]".
+func (ci confInterval) pctString() string {
+	return fmt.Sprintf("[%4.1f, %4.1f]", ci.low, ci.high)
+}
+
+// Does the interval include the given value?
+func (ci confInterval) includes(value float64) bool {
+	return ci.low <= value && value <= ci.high
+}
+
+// Bootstrap confidence intervals of a state's averages.
+type bootstrapResult struct {
+	valid    bool         // Were there enough polls (at least 2) to resample?
+	demCI    confInterval // Dem average
+	gopCI    confInterval // Gop average
+	marginCI confInterval // Margin (Dem - Gop) average
+}
+
+// Percentile interval of the sorted values for the given confidence level.
+func percentileInterval(sorted []float64, level float64) confInterval {
+	num := len(sorted)
+	tail := (1.0 - level) / 2.0
+	ixLow := int(tail * float64(num))
+	ixHigh := int((1.0-tail)*float64(num)) - 1
+	if ixHigh < ixLow {
+		ixHigh = ixLow
+	}
+	return confInterval{low: sorted[ixLow], high: sorted[ixHigh]}
+}
+
+/*
+Bootstrap the averages of the given polls.
+
+	Draw len(polls) polls with replacement, BootstrapSamples times, and average each draw.
+	The interval is the percentile interval of the averages at ConfidenceLevel.
+
+The random generator is seeded with BootstrapSeed and the state code so that every run gives the same intervals.
+*/
+func bootstrapAverages(stcode string, polls []statePoll) bootstrapResult {
+	glob := global.GetGlobalRef()
+	num := len(polls)
+	if num < 2 || glob.BootstrapSamples < 1 {
+		return bootstrapResult{}
+	}
+	hasher := fnv.New64a()
+	hasher.Write([]byte(stcode))
+	rng := rand.New(rand.NewSource(glob.BootstrapSeed ^ int64(hasher.Sum64())))
+
+	demAverages := make([]float64, glob.BootstrapSamples)
+	gopAverages := make([]float64, glob.BootstrapSamples)
+	marginAverages := make([]float64, glob.BootstrapSamples)
+	for ii := 0; ii < glob.BootstrapSamples; ii++ {
+		sumDem := 0.0
+		sumGop := 0.0
+		for jj := 0; jj < num; jj++ {
+			poll := polls[rng.Intn(num)]
+			sumDem += poll.pctDem
+			sumGop += poll.pctGop
+		}
+		demAverages[ii] = sumDem / float64(num)
+		gopAverages[ii] = sumGop / float64(num)
+		marginAverages[ii] = demAverages[ii] - gopAverages[ii]
+	}
+	sort.Float64s(demAverages)
+	sort.Float64s(gopAverages)
+	sort.Float64s(marginAverages)
+	return bootstrapResult{
+		valid:    true,
+		demCI:    percentileInterval(demAverages, glob.ConfidenceLevel),
+		gopCI:    percentileInterval(gopAverages, glob.ConfidenceLevel),
+		marginCI: percentileInterval(marginAverages, glob.ConfidenceLevel),
+	}
+}
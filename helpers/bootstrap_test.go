@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"testing"
+)
+
+func TestBootstrapAverages(t *testing.T) {
+	glob := setTestGlobals()
+	varied := []statePoll{
+		{endDate: testDay(9), pctDem: 49.0, pctGop: 45.0},
+		{endDate: testDay(7), pctDem: 46.0, pctGop: 47.0},
+		{endDate: testDay(5), pctDem: 51.0, pctGop: 44.0},
+		{endDate: testDay(3), pctDem: 47.0, pctGop: 48.0},
+	}
+	identical := []statePoll{
+		{endDate: testDay(9), pctDem: 48.0, pctGop: 46.0},
+		{endDate: testDay(2), pctDem: 48.0, pctGop: 46.0},
+	}
+
+	tests := []struct {
+		name      string
+		polls     []statePoll
+		samples   int
+		wantValid bool
+	}{
+		{"one poll", varied[:1], 1000, false},
+		{"no resamples", varied, 0, false},
+		{"identical polls", identical, 1000, true},
+		{"varied polls", varied, 1000, true},
+	}
+	for _, tt := range tests {
+		glob.BootstrapSamples = tt.samples
+		got := bootstrapAverages("PA", tt.polls)
+		if got.valid != tt.wantValid {
+			t.Errorf("%s: valid = %t, want %t", tt.name, got.valid, tt.wantValid)
+			continue
+		}
+		if !got.valid {
+			continue
+		}
+
+		// Each interval contains the plain average and lies within the range of the polls.
+		sumDem, sumGop := 0.0, 0.0
+		minDem, maxDem := tt.polls[0].pctDem, tt.polls[0].pctDem
+		for _, poll := range tt.polls {
+			sumDem += poll.pctDem
+			sumGop += poll.pctGop
+			minDem = min(minDem, poll.pctDem)
+			maxDem = max(maxDem, poll.pctDem)
+		}
+		aveDem := sumDem / float64(len(tt.polls))
+		aveGop := sumGop / float64(len(tt.polls))
+		if !got.demCI.includes(aveDem) || !got.gopCI.includes(aveGop) || !got.marginCI.includes(aveDem-aveGop) {
+			t.Errorf("%s: intervals Dem %s Gop %s Margin %s do not contain the averages", tt.name,
+				got.demCI.pctString(), got.gopCI.pctString(), got.marginCI)
+		}
+		if got.demCI.low < minDem || got.demCI.high > maxDem {
+			t.Errorf("%s: Dem interval %s outside the polls [%.1f, %.1f]", tt.name, got.demCI.pctString(), minDem, maxDem)
+		}
+
+		// The same seed and state give the same intervals.
+		if again := bootstrapAverages("PA", tt.polls); again != got {
+			t.Errorf("%s: second run %+v, want %+v", tt.name, again, got)
+		}
+	}
+
+	// Identical polls leave no doubt.
+	glob.BootstrapSamples = 1000
+	got := bootstrapAverages("PA", identical)
+	if got.demCI.low != 48.0 || got.demCI.high != 48.0 || got.marginCI.low != 2.0 || got.marginCI.high != 2.0 {
+		t.Errorf("identical polls: Dem %s Margin %s, want [48.0, 48.0] and [+2.0, +2.0]", got.demCI.pctString(), got.marginCI)
+	}
+}
@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReportAlgorithms - Run every registered ECV award algorithm on the same polling averages and compare.
+func ReportAlgorithms() {
+	opts := currentOptions()
+	baseline := computeEC(opts)
+
+	// Award every state with every algorithm.
+	awarded := make([][]stateResult, len(algorithmRegistry))
+	for ix, algorithm := range algorithmRegistry {
+		algOpts := opts
+		algOpts.algorithm = algorithm
+		awarded[ix] = make([]stateResult, len(baseline))
+		copy(awarded[ix], baseline)
+		for jj := range awarded[ix] {
+			awardState(&awarded[ix][jj], algOpts)
+		}
+	}
+
+	// Header.
+	header := "St     EV  Margin"
+	for ix := range algorithmRegistry {
+		header += fmt.Sprintf("  %-6s", fmt.Sprintf("Alg%d", ix+1))
+	}
+	prtDivider := strings.Repeat("-", len(header)+4)
+	fmt.Println()
+	for ix, algorithm := range algorithmRegistry {
+		fmt.Printf("Alg%d = %s\n", ix+1, algorithm.Name())
+	}
+	fmt.Println()
+	fmt.Println(strings.TrimRight(header, " "))
+	fmt.Println(prtDivider)
+
+	// One line per state, marking disagreement.
+	reported := make([][]stateResult, len(algorithmRegistry))
+	counterDisagree := 0
+	for jj, result := range baseline {
+		if !stateSelected(result.entry.Stcode) {
+			continue
+		}
+		line := fmt.Sprintf("%-4s  %3d  %+6.1f", result.entry.Stcode, result.entry.Votes, result.margin())
+		disagree := false
+		for ix := range algorithmRegistry {
+			reported[ix] = append(reported[ix], awarded[ix][jj])
+			line += fmt.Sprintf("  %-6s", awarded[ix][jj].leader)
+			if awarded[ix][jj].leader != awarded[0][jj].leader {
+				disagree = true
+			}
+		}
+		if disagree {
+			counterDisagree++
+			line += "  <<"
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+	fmt.Println(prtDivider)
+	fmt.Printf("<< The algorithms disagree (%d states).\n", counterDisagree)
+
+	// Totals per algorithm.
+	fmt.Println("\nAlgorithm          Dem EV  Gop EV  Tossup EV")
+	fmt.Println(prtDivider)
+	for ix, algorithm := range algorithmRegistry {
+		totals := tallyEC(reported[ix])
+		fmt.Printf("%-16s  %6d  %6d  %9d\n", algorithm.Name(), totals.demECV, totals.gopECV, totals.tossupECV)
+	}
+}
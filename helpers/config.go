@@ -5,16 +5,46 @@ import (
 	"log"
 	"os"
 	"ppolls2024/global"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type paramsStruct struct {
-	DateThreshold    string `yaml:"DateThreshold"`
-	ECVAlgorithm     string `yaml:"ECVAlgorithm"`
-	PollHistoryLimit string `yaml:"PollHistoryLimit"`
-	PlotHeight       string `yaml:"PlotHeight"`
-	PlotWidth        string `yaml:"PlotWidth"`
-	TossupThreshold  string `yaml:"TossupThreshold"`
+	BacktestCycle    string            `yaml:"BacktestCycle"`
+	BacktestDays     string            `yaml:"BacktestDaysBefore"`
+	BacktestElection string            `yaml:"BacktestElectionDay"`
+	BacktestLimits   string            `yaml:"BacktestHistoryLimits"`
+	BacktestResults  string            `yaml:"BacktestResults"`
+	BacktestTossups  string            `yaml:"BacktestTossupThresholds"`
+	BootstrapSamples string            `yaml:"BootstrapSamples"`
+	BootstrapSeed    string            `yaml:"BootstrapSeed"`
+	BootstrapTossup  string            `yaml:"BootstrapTossup"`
+	ConfidenceLevel  string            `yaml:"ConfidenceLevel"`
+	CoverageMinPolls string            `yaml:"CoverageMinPolls"`
+	DateThreshold    string            `yaml:"DateThreshold"`
+	DefSampleSize    string            `yaml:"DefaultSampleSize"`
+	DiffMarginDelta  string            `yaml:"DiffMarginDelta"`
+	ECVAlgorithm     string            `yaml:"ECVAlgorithm"`
+	Groups           map[string]string `yaml:"Groups"`
+	HouseEffectMin   string            `yaml:"HouseEffectMinPolls"`
+	HouseEffectWin   string            `yaml:"HouseEffectWindow"`
+	PollHistoryLimit string            `yaml:"PollHistoryLimit"`
+	KalmanDrift      string            `yaml:"KalmanDrift"`
+	LoessSpan        string            `yaml:"LoessSpan"`
+	PlotDPI          string            `yaml:"PlotDPI"`
+	PlotFileName     string            `yaml:"PlotFileName"`
+	PlotFormats      string            `yaml:"PlotFormats"`
+	PlotHeight       string            `yaml:"PlotHeight"`
+	PriorFallback    string            `yaml:"PriorFallback"`
+	PlotWidth        string            `yaml:"PlotWidth"`
+	SmoothedAverage  string            `yaml:"SmoothedAverage"`
+	Smoother         string            `yaml:"Smoother"`
+	StaleAction      string            `yaml:"StaleAction"`
+	StaleDays        string            `yaml:"StaleDays"`
+	TossupThreshold  string            `yaml:"TossupThreshold"`
+	TrendHalfLife    string            `yaml:"TrendHalfLife"`
+	TrendWindow      string            `yaml:"TrendWindow"`
 }
 
 func GetConfig() {
@@ -36,12 +66,27 @@ func GetConfig() {
 	}
 	log.Printf("GetConfig: DateThreshold: %s", params.DateThreshold)
 
-	glob.ECVAlgorithm, err = strconv.Atoi(params.ECVAlgorithm)
+	algorithm := LookupAlgorithm(params.ECVAlgorithm)
+	if algorithm == nil {
+		log.Fatalf("GetConfig: ECVAlgorithm (%s) from %s is not a registered algorithm (see --list-algorithms)\n", params.ECVAlgorithm, glob.CfgFile)
+	}
+	glob.ECVAlgorithm = algorithm.Name()
+	log.Printf("GetConfig: ECVAlgorithm: %s", glob.ECVAlgorithm)
+
+	glob.HouseEffectMin, err = strconv.Atoi(params.HouseEffectMin)
 	if err != nil {
+		log.Fatalf("strconv.Atoi(HouseEffectMinPolls) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	log.Printf("GetConfig: HouseEffectMinPolls: %d", glob.HouseEffectMin)
 
-		log.Fatalf("strconv.Atoi(ECVAlgorithm) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	glob.HouseEffectWin, err = strconv.Atoi(params.HouseEffectWin)
+	if err != nil {
+		log.Fatalf("strconv.Atoi(HouseEffectWindow) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	if glob.HouseEffectWin < 0 {
+		log.Fatalf("GetConfig: HouseEffectWindow (%d) from %s must not be negative\n", glob.HouseEffectWin, glob.CfgFile)
 	}
-	log.Printf("GetConfig: ECVAlgorithm: %d", glob.ECVAlgorithm)
+	log.Printf("GetConfig: HouseEffectWindow: %d", glob.HouseEffectWin)
 
 	glob.PlotWidth, err = strconv.ParseFloat(params.PlotWidth, 64)
 	if err != nil {
@@ -55,16 +100,244 @@ func GetConfig() {
 	}
 	log.Printf("GetConfig: PlotHeight: %f", glob.PlotHeight)
 
+	glob.PlotFormats, err = ParsePlotFormats(params.PlotFormats)
+	if err != nil {
+		log.Fatalf("GetConfig: PlotFormats from %s: %s\n", glob.CfgFile, err.Error())
+	}
+	log.Printf("GetConfig: PlotFormats: %s", strings.Join(glob.PlotFormats, ","))
+
+	glob.PlotDPI, err = strconv.Atoi(params.PlotDPI)
+	if err != nil {
+		log.Fatalf("strconv.Atoi(PlotDPI) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	if glob.PlotDPI < 1 {
+		log.Fatalf("GetConfig: PlotDPI (%d) from %s must be positive\n", glob.PlotDPI, glob.CfgFile)
+	}
+	log.Printf("GetConfig: PlotDPI: %d", glob.PlotDPI)
+
+	glob.PlotFileName = strings.TrimSpace(params.PlotFileName)
+	if !strings.Contains(glob.PlotFileName, "{name}") || strings.Contains(glob.PlotFileName, "/") {
+		log.Fatalf("GetConfig: PlotFileName (%s) from %s must contain {name} and no \"/\"\n", params.PlotFileName, glob.CfgFile)
+	}
+	log.Printf("GetConfig: PlotFileName: %s", glob.PlotFileName)
+
 	glob.PollHistoryLimit, err = strconv.Atoi(params.PollHistoryLimit)
 	if err != nil {
 		log.Fatalf("strconv.Atoi(PollHistoryLimit) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
 	}
 	log.Printf("GetConfig: PollHistoryLimit: %d", glob.PollHistoryLimit)
 
+	glob.PriorFallback = strings.ToLower(strings.TrimSpace(params.PriorFallback))
+	switch glob.PriorFallback {
+	case "none", "prior", "swing":
+	default:
+		log.Fatalf("GetConfig: PriorFallback (%s) from %s must be none, prior, or swing\n", params.PriorFallback, glob.CfgFile)
+	}
+	log.Printf("GetConfig: PriorFallback: %s", glob.PriorFallback)
+
+	glob.StaleDays, err = strconv.Atoi(params.StaleDays)
+	if err != nil {
+		log.Fatalf("strconv.Atoi(StaleDays) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	if glob.StaleDays < 0 {
+		log.Fatalf("GetConfig: StaleDays (%d) from %s must not be negative\n", glob.StaleDays, glob.CfgFile)
+	}
+	log.Printf("GetConfig: StaleDays: %d", glob.StaleDays)
+
+	glob.StaleAction = strings.ToLower(strings.TrimSpace(params.StaleAction))
+	if glob.StaleAction != "mark" && glob.StaleAction != "lean" {
+		log.Fatalf("GetConfig: StaleAction (%s) from %s must be mark or lean\n", params.StaleAction, glob.CfgFile)
+	}
+	log.Printf("GetConfig: StaleAction: %s", glob.StaleAction)
+
 	glob.TossupThreshold, err = strconv.ParseFloat(params.TossupThreshold, 64)
 	if err != nil {
 		log.Fatalf("GetConfig: strconv.ParseFloat(TossupThreshold) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
 	}
 	log.Printf("GetConfig: TossupThreshold: %f", glob.TossupThreshold)
 
+	glob.Groups = nil
+	for name, list := range params.Groups {
+		group := global.Group_t{Name: name}
+		switch strings.ToUpper(name) {
+		case "BATTLEGROUND", "STRONGLYDEM", "STRONGLYGOP":
+			log.Fatalf("GetConfig: Group name %s from %s is reserved\n", name, glob.CfgFile)
+		}
+		if findState(NormalizeStcode(name)) != nil {
+			log.Fatalf("GetConfig: Group name %s from %s is a state code\n", name, glob.CfgFile)
+		}
+		for _, stcode := range strings.Split(list, ",") {
+			stcode = NormalizeStcode(stcode)
+			if stcode == "" {
+				continue
+			}
+			if findState(stcode) == nil {
+				log.Fatalf("GetConfig: Group %s from %s has an invalid state code: %s\n", name, glob.CfgFile, stcode)
+			}
+			group.States = append(group.States, stcode)
+		}
+		glob.Groups = append(glob.Groups, group)
+	}
+	sort.Slice(glob.Groups, func(ii, jj int) bool { return glob.Groups[ii].Name < glob.Groups[jj].Name })
+	for _, group := range glob.Groups {
+		log.Printf("GetConfig: Group %s: %v (%d)", group.Name, group.States, len(group.States))
+	}
+
+	glob.TrendHalfLife, err = strconv.ParseFloat(params.TrendHalfLife, 64)
+	if err != nil {
+		log.Fatalf("GetConfig: strconv.ParseFloat(TrendHalfLife) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	if glob.TrendHalfLife <= 0.0 {
+		log.Fatalf("GetConfig: TrendHalfLife (%f) from %s must be positive\n", glob.TrendHalfLife, glob.CfgFile)
+	}
+	log.Printf("GetConfig: TrendHalfLife: %f", glob.TrendHalfLife)
+
+	glob.TrendWindow, err = strconv.Atoi(params.TrendWindow)
+	if err != nil {
+		log.Fatalf("strconv.Atoi(TrendWindow) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	if glob.TrendWindow < 0 {
+		log.Fatalf("GetConfig: TrendWindow (%d) from %s must not be negative\n", glob.TrendWindow, glob.CfgFile)
+	}
+	log.Printf("GetConfig: TrendWindow: %d", glob.TrendWindow)
+
+	glob.ConfidenceLevel, err = strconv.ParseFloat(params.ConfidenceLevel, 64)
+	if err != nil {
+		log.Fatalf("GetConfig: strconv.ParseFloat(ConfidenceLevel) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	if glob.ConfidenceLevel <= 0.0 || glob.ConfidenceLevel >= 1.0 {
+		log.Fatalf("GetConfig: ConfidenceLevel (%f) from %s must be between 0 and 1 exclusive\n", glob.ConfidenceLevel, glob.CfgFile)
+	}
+	log.Printf("GetConfig: ConfidenceLevel: %f", glob.ConfidenceLevel)
+
+	glob.DefSampleSize, err = strconv.Atoi(params.DefSampleSize)
+	if err != nil {
+		log.Fatalf("strconv.Atoi(DefaultSampleSize) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	if glob.DefSampleSize < 1 {
+		log.Fatalf("GetConfig: DefaultSampleSize (%d) from %s must be positive\n", glob.DefSampleSize, glob.CfgFile)
+	}
+	log.Printf("GetConfig: DefaultSampleSize: %d", glob.DefSampleSize)
+
+	glob.DiffMarginDelta, err = strconv.ParseFloat(params.DiffMarginDelta, 64)
+	if err != nil {
+		log.Fatalf("GetConfig: strconv.ParseFloat(DiffMarginDelta) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	log.Printf("GetConfig: DiffMarginDelta: %f", glob.DiffMarginDelta)
+
+	glob.BacktestCycle, err = strconv.Atoi(params.BacktestCycle)
+	if err != nil {
+		log.Fatalf("strconv.Atoi(BacktestCycle) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	log.Printf("GetConfig: BacktestCycle: %d", glob.BacktestCycle)
+
+	glob.BacktestElection, err = YYYY_MM_DDtoTime(params.BacktestElection)
+	if err != nil {
+		log.Fatalf("GetConfig: Cannot parse BacktestElectionDay: %s, reason: %s\n", params.BacktestElection, err.Error())
+	}
+	log.Printf("GetConfig: BacktestElectionDay: %s", params.BacktestElection)
+
+	glob.BacktestResults = params.BacktestResults
+	log.Printf("GetConfig: BacktestResults: %s", glob.BacktestResults)
+
+	glob.BacktestDays = parseIntList("BacktestDaysBefore", params.BacktestDays, 0)
+	log.Printf("GetConfig: BacktestDaysBefore: %v", glob.BacktestDays)
+
+	glob.BacktestLimits = parseIntList("BacktestHistoryLimits", params.BacktestLimits, 1)
+	log.Printf("GetConfig: BacktestHistoryLimits: %v", glob.BacktestLimits)
+
+	glob.BacktestTossups = parseFloatList("BacktestTossupThresholds", params.BacktestTossups)
+	log.Printf("GetConfig: BacktestTossupThresholds: %v", glob.BacktestTossups)
+
+	glob.BootstrapSamples, err = strconv.Atoi(params.BootstrapSamples)
+	if err != nil {
+		log.Fatalf("strconv.Atoi(BootstrapSamples) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	if glob.BootstrapSamples < 0 {
+		log.Fatalf("GetConfig: BootstrapSamples (%d) from %s must not be negative\n", glob.BootstrapSamples, glob.CfgFile)
+	}
+	log.Printf("GetConfig: BootstrapSamples: %d", glob.BootstrapSamples)
+
+	glob.BootstrapSeed, err = strconv.ParseInt(params.BootstrapSeed, 10, 64)
+	if err != nil {
+		log.Fatalf("strconv.ParseInt(BootstrapSeed) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	log.Printf("GetConfig: BootstrapSeed: %d", glob.BootstrapSeed)
+
+	glob.BootstrapTossup, err = strconv.ParseBool(params.BootstrapTossup)
+	if err != nil {
+		log.Fatalf("strconv.ParseBool(BootstrapTossup) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	log.Printf("GetConfig: BootstrapTossup: %t", glob.BootstrapTossup)
+
+	glob.Smoother = strings.ToLower(strings.TrimSpace(params.Smoother))
+	if !searchSlice(smootherNames, glob.Smoother) {
+		log.Fatalf("GetConfig: Smoother (%s) from %s must be one of %v\n", params.Smoother, glob.CfgFile, smootherNames)
+	}
+	log.Printf("GetConfig: Smoother: %s", glob.Smoother)
+
+	glob.SmoothedAverage, err = strconv.ParseBool(params.SmoothedAverage)
+	if err != nil {
+		log.Fatalf("strconv.ParseBool(SmoothedAverage) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	log.Printf("GetConfig: SmoothedAverage: %t", glob.SmoothedAverage)
+
+	glob.LoessSpan, err = strconv.Atoi(params.LoessSpan)
+	if err != nil {
+		log.Fatalf("strconv.Atoi(LoessSpan) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	if glob.LoessSpan < 1 {
+		log.Fatalf("GetConfig: LoessSpan (%d) from %s must be positive\n", glob.LoessSpan, glob.CfgFile)
+	}
+	log.Printf("GetConfig: LoessSpan: %d", glob.LoessSpan)
+
+	glob.KalmanDrift, err = strconv.ParseFloat(params.KalmanDrift, 64)
+	if err != nil {
+		log.Fatalf("GetConfig: strconv.ParseFloat(KalmanDrift) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	if glob.KalmanDrift <= 0.0 {
+		log.Fatalf("GetConfig: KalmanDrift (%f) from %s must be positive\n", glob.KalmanDrift, glob.CfgFile)
+	}
+	log.Printf("GetConfig: KalmanDrift: %f", glob.KalmanDrift)
+
+	glob.CoverageMinPolls, err = strconv.Atoi(params.CoverageMinPolls)
+	if err != nil {
+		log.Fatalf("strconv.Atoi(CoverageMinPolls) from %s failed, reason: %s\n", glob.CfgFile, err.Error())
+	}
+	log.Printf("GetConfig: CoverageMinPolls: %d", glob.CoverageMinPolls)
+
+}
+
+// Parse a comma-separated list of integers, each at least the given minimum, from the configuration file.
+func parseIntList(key string, list string, minimum int) []int {
+	glob := global.GetGlobalRef()
+	var values []int
+	for _, item := range strings.Split(list, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			log.Fatalf("strconv.Atoi(%s) from %s failed, reason: %s\n", key, glob.CfgFile, err.Error())
+		}
+		if value < minimum {
+			log.Fatalf("GetConfig: %s (%d) from %s must be at least %d\n", key, value, glob.CfgFile, minimum)
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// Parse a comma-separated list of non-negative floating-point numbers from the configuration file.
+func parseFloatList(key string, list string) []float64 {
+	glob := global.GetGlobalRef()
+	var values []float64
+	for _, item := range strings.Split(list, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+		if err != nil {
+			log.Fatalf("GetConfig: strconv.ParseFloat(%s) from %s failed, reason: %s\n", key, glob.CfgFile, err.Error())
+		}
+		if value < 0.0 {
+			log.Fatalf("GetConfig: %s (%f) from %s must not be negative\n", key, value, glob.CfgFile)
+		}
+		values = append(values, value)
+	}
+	return values
 }
@@ -0,0 +1,168 @@
+package helpers
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"ppolls2024/global"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette"
+	"gonum.org/v1/plot/palette/moreland"
+	"gonum.org/v1/plot/plotter"
+)
+
+// ReportCoverage - Polling coverage of every state table entry since DateThreshold.
+func ReportCoverage() {
+	glob := global.GetGlobalRef()
+	prtDivider := "--------------------------------------------------"
+	fmt.Printf("\nPolling coverage since %s\n", glob.DateThreshold.Format("2006-01-02"))
+	fmt.Println("St    Cat   EV  Polls  Last Poll    Age")
+	fmt.Println(prtDivider)
+	counterFlagged := 0
+	counterUnpolled := 0
+	for _, result := range computeEC(currentOptions()) {
+		if !stateSelected(result.entry.Stcode) {
+			continue
+		}
+		lastPoll := "--"
+		ageString := "--"
+		if len(result.polls) > 0 {
+			lastPoll = result.polls[0].endDate.Format("2006-01-02")
+			ageString = fmt.Sprintf("%d", pollAgeDays(result.polls[0].endDate, global.DummyTime))
+		} else {
+			counterUnpolled++
+		}
+		marker := ""
+		if result.entry.Category == "B" && len(result.polls) < glob.CoverageMinPolls {
+			marker = "  <<"
+			counterFlagged++
+		}
+		fmt.Printf("%-4s   %s   %3d  %5d  %-10s  %4s%s\n", result.entry.Stcode, result.entry.Category,
+			result.entry.Votes, len(result.polls), lastPoll, ageString, marker)
+	}
+	fmt.Println(prtDivider)
+	fmt.Println("Cat: B = battleground, D = strongly Dem, G = strongly Gop.")
+	fmt.Println("Age: days from the state's newest poll to today.")
+	fmt.Printf("<< Battleground with fewer than %d polls (%d states). No polls: %d states.\n",
+		glob.CoverageMinPolls, counterFlagged, counterUnpolled)
+}
+
+// Grid of poll counts per state (row) and week (column) for the coverage heat map.
+type coverageGrid struct {
+	weekStart []time.Time // Start of each week (column)
+	stcodes   []string    // State codes (rows), bottom to top
+	counts    [][]int     // counts[row][column]
+	maxCount  int         // Largest count
+}
+
+func (g *coverageGrid) Dims() (c, r int)   { return len(g.weekStart), len(g.stcodes) }
+func (g *coverageGrid) Z(c, r int) float64 { return float64(g.counts[r][c]) }
+func (g *coverageGrid) X(c int) float64 {
+	return float64(g.weekStart[c].Add(84 * time.Hour).Unix()) // middle of the week
+}
+func (g *coverageGrid) Y(r int) float64 { return float64(r) }
+func (g *coverageGrid) Min() float64    { return 0.0 }
+func (g *coverageGrid) Max() float64 {
+	if g.maxCount < 1 {
+		return 1.0
+	}
+	return float64(g.maxCount)
+}
+
+// Heat map colours for counts 0 to maxCount: white for no polls, then black-body colours darkening to near black.
+func coveragePalette(maxCount int) palette.Palette {
+	colorMap := moreland.BlackBody()
+	colorMap.SetMin(0.0)
+	colorMap.SetMax(1.0)
+	if maxCount < 1 {
+		maxCount = 1
+	}
+	colors := []color.Color{color.White}
+	for count := 1; count <= maxCount; count++ {
+		colour, err := colorMap.At(0.8 - 0.7*float64(count-1)/float64(maxCount))
+		if err != nil {
+			log.Fatalf("coveragePalette: colorMap.At failed, reason: %s\n", err.Error())
+		}
+		colors = append(colors, colour)
+	}
+	return coverageColors(colors)
+}
+
+// Palette of the coverage heat map.
+type coverageColors []color.Color
+
+func (cc coverageColors) Colors() []color.Color { return cc }
+
+/*
+Plot the polling coverage heat map of the given computation: one row per selected state,
+one column per week since DateThreshold, the colour showing the number of polls that ended in that week.
+*/
+func plotCoverage(computed []stateResult) {
+	glob := global.GetGlobalRef()
+	grid := &coverageGrid{}
+	var results []stateResult
+	var newest time.Time
+	for _, result := range computed {
+		if !stateSelected(result.entry.Stcode) {
+			continue
+		}
+		results = append(results, result)
+		if len(result.polls) > 0 && result.polls[0].endDate.After(newest) {
+			newest = result.polls[0].endDate
+		}
+	}
+	if newest.IsZero() {
+		log.Println("plotCoverage: no polls, no coverage plot")
+		return
+	}
+
+	// Weeks from DateThreshold (or the oldest poll) to the newest poll.
+	oldest := glob.DateThreshold
+	if oldest == global.DummyTime {
+		oldest = newest
+		for _, result := range results {
+			if len(result.polls) > 0 && result.polls[len(result.polls)-1].endDate.Before(oldest) {
+				oldest = result.polls[len(result.polls)-1].endDate
+			}
+		}
+	}
+	for week := oldest; !week.After(newest); week = week.AddDate(0, 0, 7) {
+		grid.weekStart = append(grid.weekStart, week)
+	}
+
+	// Rows from the bottom up, so that the first state table entry is at the top.
+	for ix := len(results) - 1; ix >= 0; ix-- {
+		counts := make([]int, len(grid.weekStart))
+		for _, poll := range results[ix].polls {
+			column := int(poll.endDate.Sub(oldest).Hours() / (24.0 * 7.0))
+			if column >= 0 && column < len(counts) {
+				counts[column]++
+				if counts[column] > grid.maxCount {
+					grid.maxCount = counts[column]
+				}
+			}
+		}
+		grid.stcodes = append(grid.stcodes, results[ix].entry.Stcode)
+		grid.counts = append(grid.counts, counts)
+	}
+
+	plt := plot.New()
+	plt.Title.Text = fmt.Sprintf("Polls per Week (darkest = %d)", grid.maxCount)
+	plt.X.Tick.Marker = plot.TimeTicks{Format: "Jan 02"}
+	var ticks []plot.Tick
+	for row, stcode := range grid.stcodes {
+		ticks = append(ticks, plot.Tick{Value: float64(row), Label: stcode})
+	}
+	plt.Y.Tick.Marker = plot.ConstantTicks(ticks)
+	plt.Add(plotter.NewHeatMap(grid, coveragePalette(grid.maxCount)))
+
+	// One row per state: make the plot taller when there are many states.
+	height := glob.PlotHeight
+	if rows := float64(len(grid.stcodes)) / 20.0; rows > 1.0 {
+		height *= rows
+	}
+	savePlot(plt, glob.PlotWidth, height, "coverage", global.DummyTime, glob.PlotFormats)
+	log.Printf("Coverage plot: %d states, %d weeks\n", len(grid.stcodes), len(grid.weekStart))
+}
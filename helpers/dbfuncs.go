@@ -23,18 +23,39 @@ const colPctZero = "pct_zero"
 const colStartDate = "start_date"
 const colEndDate = "end_date"
 const colPollster = "pollster"
+const colSampleSize = "sample_size"
+
+// Smoothed table: one row per state, day, and smoother
+const tableSmoothed = "smoothed"
+
+// Smoothed table columns (besides colState, colPctDem, and colPctGop)
+const colDate = "date"
+const colSmoother = "smoother"
+
+// Forecast history table: one row per as-of date, ECV algorithm, and settings
+const tableForecast = "forecast_history"
+
+// Forecast history table columns (besides colDateStamp and colTimeStamp)
+const colAsOf = "as_of"
+const colAlgorithm = "algorithm"
+const colSettings = "settings"
+const colDemECV = "dem_ecv"
+const colGopECV = "gop_ecv"
+const colTossupECV = "tossup_ecv"
+const colLeaders = "leaders"
 
 // Record insertion interface struct
 const ixEndDate = "ix_end_date"
 
 // Database parameters
 type dbparams struct {
-	state     string
-	startDate string
-	endDate   string
-	pctDem    float64
-	pctGop    float64
-	pollster  string
+	state      string
+	startDate  string
+	endDate    string
+	pctDem     float64
+	pctGop     float64
+	pollster   string
+	sampleSize int // 0 = unknown
 }
 
 // Assigned and used at run-time
@@ -92,6 +113,8 @@ Internal function to initialise a jacotest database.
 * Create database (includes file creation/re-creation).
 * Create history table and all of its columns, a combination of which is the primary index.
 * Create secondary indexes.
+* Create the smoothed table.
+* Create the forecast history table.
 */
 func initDB() {
 
@@ -108,18 +131,90 @@ func initDB() {
 	sqlText += colPctDem + " FLOAT NOT NULL, "
 	sqlText += colPctGop + " FLOAT NOT NULL, "
 	sqlText += colPollster + " VARCHAR NOT NULL, "
+	sqlText += colSampleSize + " INTEGER NOT NULL DEFAULT 0, "
 	sqlText += "PRIMARY KEY (" + colState + ", " + colEndDate + ") )"
 	sqlFunc(sqlText)
 
 	sqlText = "CREATE INDEX " + ixEndDate + " ON " + tableHistory + " (" + colEndDate + ")"
 	sqlFunc(sqlText)
 
+	createSmoothedTable()
+	createForecastTable()
+
 	if sqltracing {
 		log.Println("initDB: End")
 	}
 
 }
 
+/*
+Internal function to create the smoothed table if it is not present.
+*/
+func createSmoothedTable() {
+
+	sqlText := "CREATE TABLE IF NOT EXISTS " + tableSmoothed + " ("
+	sqlText += colState + " VARCHAR NOT NULL, "
+	sqlText += colDate + " VARCHAR NOT NULL, "
+	sqlText += colPctDem + " FLOAT NOT NULL, "
+	sqlText += colPctGop + " FLOAT NOT NULL, "
+	sqlText += colSmoother + " VARCHAR NOT NULL, "
+	sqlText += "PRIMARY KEY (" + colState + ", " + colDate + ", " + colSmoother + ") )"
+	sqlFunc(sqlText)
+
+}
+
+/*
+Internal function to create the forecast history table if it is not present.
+*/
+func createForecastTable() {
+
+	sqlText := "CREATE TABLE IF NOT EXISTS " + tableForecast + " ("
+	sqlText += colAsOf + " VARCHAR NOT NULL, "
+	sqlText += colAlgorithm + " VARCHAR NOT NULL, "
+	sqlText += colSettings + " VARCHAR NOT NULL, "
+	sqlText += colDateStamp + " VARCHAR NOT NULL, "
+	sqlText += colTimeStamp + " VARCHAR NOT NULL, "
+	sqlText += colDemECV + " INTEGER NOT NULL, "
+	sqlText += colGopECV + " INTEGER NOT NULL, "
+	sqlText += colTossupECV + " INTEGER NOT NULL, "
+	sqlText += colLeaders + " VARCHAR NOT NULL, "
+	sqlText += "PRIMARY KEY (" + colAsOf + ", " + colAlgorithm + ", " + colSettings + ") )"
+	sqlFunc(sqlText)
+
+}
+
+/*
+Internal function to add any history table columns and tables that are missing from a database created by an earlier version.
+*/
+func migrateDB() {
+
+	rows := sqlQuery("PRAGMA table_info(" + tableHistory + ")")
+	haveSampleSize := false
+	for rows.Next() {
+		var cid, notNull, primaryKey int
+		var name, colType string
+		var defaultValue sql.NullString
+		err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &primaryKey)
+		if err != nil {
+			log.Fatalf("migrateDB: rows.Scan failed, reason: %s\n", err.Error())
+		}
+		if name == colSampleSize {
+			haveSampleSize = true
+		}
+	}
+	rows.Close()
+
+	if !haveSampleSize {
+		log.Printf("migrateDB: adding column %s to table %s\n", colSampleSize, tableHistory)
+		sqlFunc("ALTER TABLE " + tableHistory + " ADD COLUMN " + colSampleSize + " INTEGER NOT NULL DEFAULT 0")
+	}
+
+	createSmoothedTable()
+
+	createForecastTable()
+
+}
+
 /*
 DBOpen - Database Open
 
@@ -169,6 +264,9 @@ func DBOpen(driverDatabase, dirDatabase, fileDatabase string) {
 
 	// sqliteDatabase stays open until process exit
 
+	// Bring an older database up to the current schema.
+	migrateDB()
+
 	if sqltracing {
 		log.Printf("DBOpen: End, existing database opened")
 	}
@@ -199,15 +297,26 @@ func DBClose() {
 
 /*
 DBStore - Store a poll record.
+
+If the poll is already in the database, its date and time stamps are kept so that they record when the poll was first loaded.
 */
 func DBStore(fields dbparams) {
 
 	dateUTC := "'" + GetUtcDate() + "'"
 	timeUTC := "'" + GetUtcTime() + "'"
-	sqlText := "INSERT OR REPLACE INTO " + tableHistory + " VALUES("
+	sqlText := "INSERT INTO " + tableHistory + " ("
+	sqlText += colDateStamp + ", " + colTimeStamp + ", " + colState + ", " + colStartDate + ", " + colEndDate + ", "
+	sqlText += colPctDem + ", " + colPctGop + ", " + colPollster + ", " + colSampleSize + ") VALUES("
 	sqlText += dateUTC + ", " + timeUTC + ",\"" + fields.state + "\", \"" + fields.startDate + "\", \"" + fields.endDate
-	caboose := fmt.Sprintf("\", %f, %f, \"%s\" )", fields.pctDem, fields.pctGop, fields.pollster)
+	caboose := fmt.Sprintf("\", %f, %f, \"%s\", %d )", fields.pctDem, fields.pctGop, fields.pollster, fields.sampleSize)
 	sqlText += caboose
+	sqlText += " ON CONFLICT(" + colState + ", " + colEndDate + ") DO UPDATE SET "
+	for ix, col := range []string{colStartDate, colPctDem, colPctGop, colPollster, colSampleSize} {
+		if ix > 0 {
+			sqlText += ", "
+		}
+		sqlText += col + " = excluded." + col
+	}
 
 	sqlFunc(sqlText)
 }
@@ -0,0 +1,84 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"ppolls2024/global"
+)
+
+// ReportDiff - Compare the Electoral College summary as of two dates.
+func ReportDiff() {
+	glob := global.GetGlobalRef()
+	date1 := glob.DiffDate1.Format("2006-01-02")
+	date2 := glob.DiffDate2.Format("2006-01-02")
+	basis := "poll end dates"
+	if glob.FlagByLoad {
+		basis = "load dates"
+	}
+	opts1 := currentOptions()
+	opts1.asOf = glob.DiffDate1
+	opts1.byLoad = glob.FlagByLoad
+	opts2 := opts1
+	opts2.asOf = glob.DiffDate2
+	results1 := computeEC(opts1)
+	results2 := computeEC(opts2)
+	prtDivider := "----------------------------------------------"
+	fmt.Printf("\nEC summary as of %s versus as of %s (by %s)\n", date1, date2, basis)
+
+	// States that changed leader.
+	counter := 0
+	fmt.Println("\nLeader changes:")
+	fmt.Println("St     EV  Leader1  Leader2  Margin1  Margin2")
+	fmt.Println(prtDivider)
+	for ix := range results1 {
+		if results1[ix].leader == results2[ix].leader {
+			continue
+		}
+		counter++
+		fmt.Printf("%-5s %3d  %-7s  %-7s  %+7.1f  %+7.1f\n",
+			results1[ix].entry.Stcode, results1[ix].entry.Votes, results1[ix].leader, results2[ix].leader,
+			results1[ix].margin(), results2[ix].margin())
+	}
+	if counter < 1 {
+		fmt.Println("none")
+	}
+
+	// States whose margin moved more than the configured threshold.
+	// States without polls as of either date have no margin to compare.
+	counter = 0
+	listNewlyPolled := ""
+	fmt.Printf("\nMargin (Dem - Gop) moves of more than %.1f points:\n", glob.DiffMarginDelta)
+	fmt.Println("St     EV  Margin1  Margin2   Change")
+	fmt.Println(prtDivider)
+	for ix := range results1 {
+		if results1[ix].pollCount < 1 || results2[ix].pollCount < 1 {
+			if results1[ix].pollCount < 1 && results2[ix].pollCount > 0 {
+				listNewlyPolled += " " + results2[ix].entry.Stcode
+			}
+			continue
+		}
+		change := results2[ix].margin() - results1[ix].margin()
+		if math.Abs(change) <= glob.DiffMarginDelta {
+			continue
+		}
+		counter++
+		fmt.Printf("%-5s %3d  %+7.1f  %+7.1f  %+7.1f\n",
+			results1[ix].entry.Stcode, results1[ix].entry.Votes, results1[ix].margin(), results2[ix].margin(), change)
+	}
+	if counter < 1 {
+		fmt.Println("none")
+	}
+	if listNewlyPolled != "" {
+		fmt.Printf("First polled after %s:%s\n", date1, listNewlyPolled)
+	}
+
+	// Net EV change per candidate.
+	totals1 := tallyEC(results1)
+	totals2 := tallyEC(results2)
+	fmt.Println("\nNet EV change:")
+	fmt.Printf("           %-10s  %-10s  Change\n", date1, date2)
+	fmt.Println(prtDivider)
+	fmt.Printf("Dem    EV:   %3d         %3d       %+4d\n", totals1.demECV, totals2.demECV, totals2.demECV-totals1.demECV)
+	fmt.Printf("Gop    EV:   %3d         %3d       %+4d\n", totals1.gopECV, totals2.gopECV, totals2.gopECV-totals1.gopECV)
+	fmt.Printf("Tossup EV:   %3d         %3d       %+4d\n", totals1.tossupECV, totals2.tossupECV, totals2.tossupECV-totals1.tossupECV)
+}
@@ -0,0 +1,427 @@
+package helpers
+
+import (
+	"fmt"
+	"log"
+	"ppolls2024/global"
+	"time"
+)
+
+// Options for the Electoral College computation.
+type ecOptions struct {
+	asOf            time.Time          // Ignore polls after this date (DummyTime = no limit)
+	byLoad          bool               // Apply asOf to the date that the poll was first loaded instead of the poll end date
+	houseAdjust     bool               // Subtract pollster house effects, estimated as of asOf, from each poll?
+	houseEffects    map[string]float64 // Pollster house effects to subtract (nil = not yet estimated or no adjustment)
+	algorithm       AwardAlgorithm     // ECV award algorithm
+	tossupThreshold float64            // Threshold of difference below which a tossup can be inferred
+	historyLimit    int                // Average at most this many of the most recent polls
+	dateThreshold   time.Time          // Ignore polls before this date
+	priorFallback   string             // Fallback for states without polls: "none", "prior", or "swing"
+	staleDays       int                // A state whose newest poll is older than this many days is stale (0 = never)
+	bootstrapTossup bool               // Is a state a tossup when its bootstrap margin interval includes 0?
+	smoother        string             // Average = the smoothed estimate at the newest poll: "loess" or "kalman" ("" = plain average)
+	confidenceLevel float64            // Confidence level of the margin-of-error test
+	defSampleSize   int                // Sample size assumed for polls that do not report one
+}
+
+// Options for the Electoral College computation as of now.
+func currentOptions() ecOptions {
+	glob := global.GetGlobalRef()
+	opts := ecOptions{asOf: global.DummyTime, byLoad: false,
+		algorithm: LookupAlgorithm(glob.ECVAlgorithm), tossupThreshold: glob.TossupThreshold,
+		historyLimit: glob.PollHistoryLimit, dateThreshold: glob.DateThreshold, priorFallback: glob.PriorFallback,
+		staleDays: glob.StaleDays, bootstrapTossup: glob.BootstrapTossup, houseAdjust: glob.FlagHouseAdjust,
+		confidenceLevel: glob.ConfidenceLevel, defSampleSize: glob.DefSampleSize}
+	if glob.SmoothedAverage && glob.Smoother != "none" {
+		opts.smoother = glob.Smoother
+	}
+	return opts
+}
+
+// Estimate the house effects as of opts.asOf, unless already estimated or not requested.
+func withHouseEffects(opts ecOptions) ecOptions {
+	if opts.houseAdjust && opts.houseEffects == nil {
+		opts.houseEffects = houseEffectMap(opts)
+	}
+	return opts
+}
+
+// Electoral College computation result for one state.
+type stateResult struct {
+	entry       global.StateTableEntry_t // State table entry
+	endDate     string                   // End date of the most recent eligible poll or "no data"
+	pollCount   int                      // Number of polls that were averaged
+	prior       bool                     // No polls: the averages are the previous cycle's result
+	viaParent   bool                     // District without polls: the averages are those of its parent state
+	ageDays     int                      // Days from the newest poll of this state to the as-of date or today (-1 = no polls)
+	stale       bool                     // Is ageDays over the staleness limit?
+	aveDemPct   float64                  // Average Dem percentage
+	aveGopPct   float64                  // Average Gop percentage
+	aveOtherPct float64                  // Average Other percentage
+	demTrend    trendResult              // Dem trend
+	gopTrend    trendResult              // Gop trend
+	otherTrend  trendResult              // Other trend
+	leader      string                   // "Dem", "Gop", or "TOSSUP"
+	otherFactor string                   // Other-factor indicator from the ECV award algorithm
+	sampleSizes []int                    // Sample sizes of the last historyLimit polls (0 = unknown), also with a smoother
+	bootstrap   bootstrapResult          // Bootstrap confidence intervals of the averages (not valid with a smoother)
+	polls       []statePoll              // All eligible polls, most recent first
+	increDem    int                      // ECV awarded to Dem
+	increGop    int                      // ECV awarded to Gop
+	increTossup int                      // ECV considered a tossup
+}
+
+// Margin = Dem percentage - Gop percentage. Positive favours Dem, negative favours Gop.
+func (sr *stateResult) margin() float64 {
+	return sr.aveDemPct - sr.aveGopPct
+}
+
+// Electoral College totals over a set of state results.
+type ecTotals struct {
+	demECV              int
+	gopECV              int
+	tossupECV           int
+	counterDemStates    int
+	counterGopStates    int
+	counterTossupStates int
+	staleECV            int // ECV of the stale states
+	staleDemECV         int // Of demECV, the ECV of stale states
+	staleGopECV         int // Of gopECV, the ECV of stale states
+	counterStaleStates  int
+	listStaleStates     string
+	listDemStates       string
+	listGopStates       string
+	listTossupStates    string
+}
+
+// One eligible poll of a state.
+type statePoll struct {
+	endDate    time.Time // Poll end date
+	pctDem     float64   // Dem percentage (after any house effect adjustment)
+	pctGop     float64   // Gop percentage (after any house effect adjustment)
+	sampleSize int       // Sample size (0 = unknown)
+	pollster   string    // Pollster name
+}
+
+// Compute the averages, trends, and leader for one state.
+func computeState(stateTableEntry global.StateTableEntry_t, opts ecOptions) stateResult {
+	glob := global.GetGlobalRef()
+	var arraySampleSize []int
+	result := stateResult{entry: stateTableEntry}
+
+	// For the given state, query from the most recent to the least recent polling.
+	sqlText := fmt.Sprintf("SELECT date_stamp, end_date, pct_dem, pct_gop, sample_size, pollster FROM history WHERE state = '%s' ORDER BY end_date DESC",
+		stateTableEntry.Stcode)
+	rows := sqlQuery(sqlText)
+	defer rows.Close()
+
+	// Collect the eligible polls, most recent first.
+	var query dbparams
+	var dateStamp string
+	for rows.Next() {
+		err := rows.Scan(&dateStamp, &query.endDate, &query.pctDem, &query.pctGop, &query.sampleSize, &query.pollster)
+		if err != nil {
+			log.Fatalf("computeState: rows.Scan failed, row count: %d, reason: %s\n", len(result.polls), err.Error())
+		}
+		tm, err := YYYY_MM_DDtoTime(query.endDate)
+		if err != nil {
+			log.Fatalf("computeState: Cannot parse start date: %s, reason: %s\n\n", query.endDate, err.Error())
+		}
+		if tm.Before(opts.dateThreshold) {
+			continue
+		}
+		if opts.asOf != global.DummyTime {
+			tmAsOf := tm
+			if opts.byLoad {
+				tmAsOf, err = YYYY_MM_DDtoTime(dateStamp)
+				if err != nil {
+					log.Fatalf("computeState: Cannot parse load date: %s, reason: %s\n\n", dateStamp, err.Error())
+				}
+			}
+			if tmAsOf.After(opts.asOf) {
+				continue
+			}
+		}
+
+		// Subtract the pollster's house effect, half from each candidate.
+		if lean, ok := opts.houseEffects[query.pollster]; ok {
+			query.pctDem -= lean / 2.0
+			query.pctGop += lean / 2.0
+		}
+
+		result.polls = append(result.polls, statePoll{endDate: tm, pctDem: query.pctDem, pctGop: query.pctGop,
+			sampleSize: query.sampleSize, pollster: query.pollster})
+	}
+
+	// Average the most recent polls, without going over the poll history threshold.
+	counterRows := 0
+	aveDemPct := 0.0
+	aveGopPct := 0.0
+	aveOtherPct := 0.0
+	endDate := ""
+	for _, poll := range result.polls {
+		counterRows += 1
+
+		// If first row, that is the end date.
+		if counterRows == 1 {
+			endDate = poll.endDate.Format("2006-01-02")
+		}
+
+		aveDemPct += poll.pctDem
+		aveGopPct += poll.pctGop
+		arraySampleSize = append(arraySampleSize, poll.sampleSize)
+
+		// Don't go over the poll history threshold.
+		if counterRows >= opts.historyLimit {
+			break
+		}
+	}
+
+	// Got any data for this state?
+	if counterRows < 1 { // NO DATA
+		endDate = "no data   "
+		var strongly bool
+		strongly = searchSlice(glob.StronglyDem, stateTableEntry.Stcode)
+		if strongly { // Strongly Democrat
+			aveDemPct = 99.9
+			aveGopPct = 0.0
+			aveOtherPct = 0.0
+		} else {
+			strongly = searchSlice(glob.StronglyGop, stateTableEntry.Stcode)
+			if strongly { // Strongly GOP
+				aveDemPct = 0.0
+				aveGopPct = 99.9
+				aveOtherPct = 0.0
+			} else { // Battleground
+				aveDemPct = 0.0
+				aveGopPct = 0.0
+				aveOtherPct = 99.9
+			}
+		}
+	} else { // We have data for this state.
+		// Averages for this state.
+		aveDemPct /= float64(counterRows)
+		aveGopPct /= float64(counterRows)
+		// Or the smoothed estimate over all eligible polls as of the newest poll.
+		if opts.smoother != "" {
+			if point, ok := smoothedEstimate(stateTableEntry.Stcode, result.polls, opts); ok {
+				aveDemPct = point.pctDem
+				aveGopPct = point.pctGop
+			}
+		}
+		aveOtherPct = CalcOther(aveDemPct, aveGopPct)
+	}
+
+	result.endDate = endDate
+	result.pollCount = counterRows
+	result.aveDemPct = aveDemPct
+	result.aveGopPct = aveGopPct
+	result.aveOtherPct = aveOtherPct
+	result.sampleSizes = arraySampleSize
+	// The bootstrap resamples the plain average, so it says nothing about a smoothed estimate.
+	if opts.smoother == "" {
+		result.bootstrap = bootstrapAverages(stateTableEntry.Stcode, result.polls[:counterRows])
+	}
+	result.demTrend, result.gopTrend, result.otherTrend = stateTrends(result.polls)
+
+	// Compute leader and the increments.
+	awardState(&result, opts)
+	return result
+}
+
+// Award input with the settings of the given options and no state.
+func awardSettings(opts ecOptions) AwardInput {
+	return AwardInput{
+		TossupThreshold: opts.tossupThreshold,
+		ConfidenceLevel: opts.confidenceLevel,
+		DefSampleSize:   opts.defSampleSize,
+	}
+}
+
+// Compute the leader and the ECV increments of a state from its averages.
+func awardState(result *stateResult, opts ecOptions) {
+	input := awardSettings(opts)
+	input.Votes = result.entry.Votes
+	input.PctDem = result.aveDemPct
+	input.PctGop = result.aveGopPct
+	input.SampleSizes = result.sampleSizes
+	award := opts.algorithm.Award(input)
+	if opts.bootstrapTossup && result.bootstrap.valid && result.bootstrap.marginCI.includes(0.0) {
+		award = awardTo("TOSSUP", result.entry.Votes, award.Factor)
+	}
+	result.leader = award.Leader
+	result.increDem = award.DemVotes
+	result.increGop = award.GopVotes
+	result.increTossup = award.TossupVotes
+	result.otherFactor = award.Factor
+}
+
+// Compute the Electoral College results for every state in the state table, in state table order.
+// With houseAdjust, the house effects are estimated from the polls up to opts.asOf.
+func computeEC(opts ecOptions) []stateResult {
+	var results []stateResult
+	opts = withHouseEffects(opts)
+	for _, stateTableEntry := range global.StateTable {
+		results = append(results, computeState(stateTableEntry, opts))
+	}
+	if opts.priorFallback != "none" {
+		applyPriors(results, opts)
+	}
+	applyDistricts(results, opts)
+	applyAges(results, opts)
+	return results
+}
+
+// Days from a poll's end date to the as-of date (DummyTime = today).
+func pollAgeDays(endDate, asOf time.Time) int {
+	if asOf == global.DummyTime {
+		asOf, _ = YYYY_MM_DDtoTime(GetUtcDate())
+	}
+	return int(asOf.Sub(endDate).Hours() / 24.0)
+}
+
+// Compute the age of each state's newest poll as of opts.asOf and flag the stale states.
+func applyAges(results []stateResult, opts ecOptions) {
+	for ii := range results {
+		result := &results[ii]
+		result.ageDays = -1
+		result.stale = false
+		if result.pollCount < 1 || len(result.polls) < 1 {
+			continue
+		}
+		result.ageDays = pollAgeDays(result.polls[0].endDate, opts.asOf)
+		result.stale = opts.staleDays > 0 && result.ageDays > opts.staleDays
+	}
+}
+
+/*
+National swing = the turnout-weighted average of (current margin - previous cycle margin) over the states with polls.
+Returns the swing and the number of states it is based on.
+Congressional districts and states missing from the turnout table do not count.
+*/
+func nationalSwing(results []stateResult) (float64, int) {
+	sumSwing := 0.0
+	sumVotes := 0.0
+	counter := 0
+	for _, result := range results {
+		if result.pollCount < 1 || result.entry.Parent != "" {
+			continue
+		}
+		turnout := global.TurnoutTableLookup(result.entry.Stcode)
+		if turnout == nil {
+			continue
+		}
+		sumSwing += float64(turnout.Votes) * (result.margin() - (turnout.PctDem - turnout.PctGop))
+		sumVotes += float64(turnout.Votes)
+		counter++
+	}
+	if sumVotes <= 0.0 {
+		return 0.0, 0
+	}
+	return sumSwing / sumVotes, counter
+}
+
+// Replace the placeholder averages of the states without polls by their previous cycle result (turnout table),
+// shifted by the national swing if opts.priorFallback is "swing".
+// Congressional districts are missing from the turnout table; applyDistricts handles them.
+func applyPriors(results []stateResult, opts ecOptions) {
+	swing := 0.0
+	if opts.priorFallback == "swing" {
+		swing, _ = nationalSwing(results)
+	}
+	for ii := range results {
+		result := &results[ii]
+		if result.pollCount > 0 {
+			continue
+		}
+		turnout := global.TurnoutTableLookup(result.entry.Stcode)
+		if turnout == nil {
+			continue
+		}
+		result.prior = true
+		result.endDate = "prior     "
+		result.aveDemPct = turnout.PctDem + swing/2.0
+		result.aveGopPct = turnout.PctGop - swing/2.0
+		result.aveOtherPct = CalcOther(result.aveDemPct, result.aveGopPct)
+		awardState(result, opts)
+	}
+}
+
+/*
+Replace the placeholder averages of the congressional districts without polls by the averages of their parent state
+(polls or prior), or else by the parent's previous cycle result, so that no placeholder reaches the tally.
+*/
+func applyDistricts(results []stateResult, opts ecOptions) {
+	byState := make(map[string]int)
+	for ix, result := range results {
+		byState[result.entry.Stcode] = ix
+	}
+	for ii := range results {
+		result := &results[ii]
+		if result.entry.Parent == "" || result.pollCount > 0 {
+			continue
+		}
+		ix, ok := byState[result.entry.Parent]
+		if !ok {
+			log.Fatalf("applyDistricts: parent state %s of %s is missing from the state table\n", result.entry.Parent, result.entry.Stcode)
+		}
+		parent := results[ix]
+		switch {
+		case parent.pollCount > 0:
+			result.viaParent = true
+			result.endDate = fmt.Sprintf("via %-6s", parent.entry.Stcode)
+			result.aveDemPct = parent.aveDemPct
+			result.aveGopPct = parent.aveGopPct
+		case parent.prior:
+			result.prior = true
+			result.endDate = "prior     "
+			result.aveDemPct = parent.aveDemPct
+			result.aveGopPct = parent.aveGopPct
+		default:
+			turnout := global.TurnoutTableLookup(parent.entry.Stcode)
+			if turnout == nil {
+				log.Fatalf("applyDistricts: parent state %s of %s is missing from the turnout table\n", parent.entry.Stcode, result.entry.Stcode)
+			}
+			result.prior = true
+			result.endDate = "prior     "
+			result.aveDemPct = turnout.PctDem
+			result.aveGopPct = turnout.PctGop
+		}
+		result.aveOtherPct = CalcOther(result.aveDemPct, result.aveGopPct)
+		awardState(result, opts)
+	}
+}
+
+// Tally the Electoral College totals over the given state results.
+func tallyEC(results []stateResult) ecTotals {
+	var totals ecTotals
+	for _, result := range results {
+		totals.demECV += result.increDem
+		totals.gopECV += result.increGop
+		totals.tossupECV += result.increTossup
+		if result.stale {
+			totals.staleECV += result.entry.Votes
+			totals.counterStaleStates++
+			totals.listStaleStates += " " + result.entry.Stcode
+		}
+		switch result.leader {
+		case "Dem":
+			totals.counterDemStates++
+			totals.listDemStates += " " + result.entry.Stcode
+			if result.stale {
+				totals.staleDemECV += result.increDem
+			}
+		case "Gop":
+			totals.counterGopStates++
+			totals.listGopStates += " " + result.entry.Stcode
+			if result.stale {
+				totals.staleGopECV += result.increGop
+			}
+		default:
+			totals.counterTossupStates++
+			totals.listTossupStates += " " + result.entry.Stcode
+		}
+	}
+	return totals
+}
@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"ppolls2024/global"
+	"testing"
+)
+
+func TestTallyEC(t *testing.T) {
+	result := func(stcode string, votes int, leader string, stale bool) stateResult {
+		res := stateResult{entry: global.StateTableEntry_t{Stcode: stcode, Votes: votes}, leader: leader, stale: stale}
+		switch leader {
+		case "Dem":
+			res.increDem = votes
+		case "Gop":
+			res.increGop = votes
+		default:
+			res.increTossup = votes
+		}
+		return res
+	}
+	tests := []struct {
+		name    string
+		results []stateResult
+		want    ecTotals
+	}{
+		{"none", nil, ecTotals{}},
+		{"one of each", []stateResult{result("CA", 54, "Dem", false), result("TX", 40, "Gop", false), result("PA", 19, "TOSSUP", false)},
+			ecTotals{demECV: 54, gopECV: 40, tossupECV: 19, counterDemStates: 1, counterGopStates: 1, counterTossupStates: 1,
+				listDemStates: " CA", listGopStates: " TX", listTossupStates: " PA"}},
+		{"stale states", []stateResult{result("MI", 15, "Dem", true), result("WI", 10, "Dem", false), result("GA", 16, "Gop", true),
+			result("NV", 6, "TOSSUP", true)},
+			ecTotals{demECV: 25, gopECV: 16, tossupECV: 6, counterDemStates: 2, counterGopStates: 1, counterTossupStates: 1,
+				staleECV: 37, staleDemECV: 15, staleGopECV: 16, counterStaleStates: 3, listStaleStates: " MI GA NV",
+				listDemStates: " MI WI", listGopStates: " GA", listTossupStates: " NV"}},
+	}
+	for _, tt := range tests {
+		if got := tallyEC(tt.results); got != tt.want {
+			t.Errorf("%s: tallyEC = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
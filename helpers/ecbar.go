@@ -0,0 +1,101 @@
+package helpers
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"ppolls2024/global"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+/*
+Draw the Electoral College summary chart: one horizontal bar stacking the Dem, Tossup, and Gop EVs,
+with the EV_TO_WIN line marked. The totals are those of the given computation (that of ReportEC).
+*/
+func plotECBar(results []stateResult) {
+	glob := global.GetGlobalRef()
+	totals := tallyEC(results)
+	totalECV := totals.demECV + totals.tossupECV + totals.gopECV
+
+	plt := plot.New()
+	plt.Title.Text = fmt.Sprintf("Electoral College: Dem %d, Tossup %d, Gop %d", totals.demECV, totals.tossupECV, totals.gopECV)
+	plt.X.Label.Text = "Electoral votes"
+	plt.X.Min, plt.X.Max = 0.0, float64(totalECV)
+	plt.X.Tick.Marker = plot.ConstantTicks([]plot.Tick{{Value: 0, Label: "0"}, {Value: float64(global.EV_TO_WIN), Label: fmt.Sprintf("%d", global.EV_TO_WIN)},
+		{Value: float64(totalECV), Label: fmt.Sprintf("%d", totalECV)}})
+	plt.HideY()
+
+	// Dem from the left, then Tossup, then Gop.
+	barWidth := vg.Length(2.0 * glob.PlotWidth * 0.12)
+	var previous *plotter.BarChart
+	var segments []*plotter.BarChart
+	for _, segment := range []struct {
+		name   string
+		votes  int
+		leader string
+	}{{"Dem", totals.demECV, "Dem"}, {"Tossup", totals.tossupECV, "TOSSUP"}, {"Gop", totals.gopECV, "Gop"}} {
+		bar, err := plotter.NewBarChart(plotter.Values{float64(segment.votes)}, barWidth*vg.Centimeter)
+		if err != nil {
+			log.Fatalf("plotECBar: plotter.NewBarChart(%s) failed, reason: %s\n", segment.name, err.Error())
+		}
+		bar.Horizontal = true
+		bar.Color = mapColour(stateResult{leader: segment.leader, aveDemPct: mapMarginFull})
+		bar.LineStyle.Color = color.White
+		if previous != nil {
+			bar.StackOn(previous)
+		}
+		plt.Add(bar)
+		segments = append(segments, bar)
+		previous = bar
+	}
+
+	// EV counts in the middle of each segment wide enough to hold them.
+	var labels plotter.XYLabels
+	var labelColours []color.Color
+	left := 0.0
+	for _, segment := range segments {
+		votes := segment.Values[0]
+		if votes >= 20.0 {
+			labels.XYs = append(labels.XYs, plotter.XY{X: left + votes/2.0, Y: 0.0})
+			labels.Labels = append(labels.Labels, fmt.Sprintf("%d", int(votes)))
+			if segment == segments[1] {
+				labelColours = append(labelColours, color.Black) // Tossup
+			} else {
+				labelColours = append(labelColours, color.White)
+			}
+		}
+		left += votes
+	}
+	if len(labels.Labels) > 0 {
+		counts, err := plotter.NewLabels(labels)
+		if err != nil {
+			log.Fatalf("plotECBar: plotter.NewLabels failed, reason: %s\n", err.Error())
+		}
+		for ix := range counts.TextStyle {
+			counts.TextStyle[ix].XAlign = draw.XCenter
+			counts.TextStyle[ix].YAlign = draw.YCenter
+			counts.TextStyle[ix].Color = labelColours[ix]
+			counts.TextStyle[ix].Font.Size = vg.Points(12)
+		}
+		plt.Add(counts)
+	}
+
+	// The line to win.
+	winLine, err := plotter.NewLine(plotter.XYs{{X: float64(global.EV_TO_WIN), Y: -0.6}, {X: float64(global.EV_TO_WIN), Y: 0.6}})
+	if err != nil {
+		log.Fatalf("plotECBar: plotter.NewLine failed, reason: %s\n", err.Error())
+	}
+	winLine.Color = color.Black
+	winLine.Width = vg.Points(2)
+	winLine.Dashes = []vg.Length{vg.Points(4), vg.Points(3)}
+	plt.Add(winLine)
+	plt.Y.Min, plt.Y.Max = -0.7, 0.7
+
+	width := 2.0 * glob.PlotWidth
+	savePlot(plt, width, width*0.3, "ecbar", global.DummyTime, glob.PlotFormats)
+	log.Printf("EC bar: Dem %d, Tossup %d, Gop %d\n", totals.demECV, totals.tossupECV, totals.gopECV)
+}
@@ -0,0 +1,181 @@
+package helpers
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"ppolls2024/global"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// One row of the forecast history table.
+type forecastEntry struct {
+	asOf      time.Time // As-of date of the computation
+	demECV    int       // ECV awarded to Dem
+	gopECV    int       // ECV awarded to Gop
+	tossupECV int       // ECV considered a tossup
+	leaders   string    // Leader of each state: "ST:leader ST:leader ..."
+}
+
+/*
+Settings of an Electoral College computation that change its results, besides the as-of date and the algorithm.
+Forecasts are only compared with forecasts of the same settings.
+The smoother, bootstrap, and house effect parameters only count when the smoother, BootstrapTossup, or the adjustment is in use.
+*/
+func forecastSettings(opts ecOptions) string {
+	glob := global.GetGlobalRef()
+	settings := fmt.Sprintf("limit=%d tossup=%.2f from=%s prior=%s confidence=%.2f sample-size=%d",
+		opts.historyLimit, opts.tossupThreshold, opts.dateThreshold.Format("2006-01-02"), opts.priorFallback,
+		opts.confidenceLevel, opts.defSampleSize)
+	switch opts.smoother {
+	case "":
+		settings += " average"
+	case "loess":
+		settings += fmt.Sprintf(" loess span=%d", glob.LoessSpan)
+	case "kalman":
+		settings += fmt.Sprintf(" kalman drift=%.3f", glob.KalmanDrift)
+	}
+	if opts.bootstrapTossup {
+		settings += fmt.Sprintf(" bootstrap-tossup samples=%d", glob.BootstrapSamples)
+	}
+	if opts.houseAdjust {
+		settings += fmt.Sprintf(" house-adjust window=%d min-polls=%d", glob.HouseEffectWin, glob.HouseEffectMin)
+	}
+	return settings
+}
+
+/*
+Store the totals and the leader of each state of an Electoral College computation in the forecast history table,
+keyed by the as-of date, the ECV algorithm, and the settings. A later run for the same key replaces the row.
+
+A computation as of now (DummyTime) is dated by the newest poll of any state,
+so that runs without new polls do not add points to the history.
+*/
+func recordForecast(asOf time.Time, opts ecOptions, results []stateResult) {
+	asOfString := GetUtcDate()
+	if asOf != global.DummyTime {
+		asOfString = asOf.Format("2006-01-02")
+	} else {
+		var newest time.Time
+		for _, result := range results {
+			if result.pollCount > 0 && result.polls[0].endDate.After(newest) {
+				newest = result.polls[0].endDate
+			}
+		}
+		if !newest.IsZero() {
+			asOfString = newest.Format("2006-01-02")
+		}
+	}
+	totals := tallyEC(results)
+	var leaders []string
+	for _, result := range results {
+		leaders = append(leaders, result.entry.Stcode+":"+result.leader)
+	}
+	sqlText := fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s, %s, %s, %s, %s, %s) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		tableForecast, colAsOf, colAlgorithm, colSettings, colDateStamp, colTimeStamp, colDemECV, colGopECV, colTossupECV, colLeaders)
+	sqlText += " ON CONFLICT(" + colAsOf + ", " + colAlgorithm + ", " + colSettings + ") DO UPDATE SET "
+	for ix, col := range []string{colDateStamp, colTimeStamp, colDemECV, colGopECV, colTossupECV, colLeaders} {
+		if ix > 0 {
+			sqlText += ", "
+		}
+		sqlText += col + " = excluded." + col
+	}
+	_, err := sqliteDatabase.Exec(sqlText, asOfString, opts.algorithm.Name(), forecastSettings(opts), GetUtcDate(), GetUtcTime(),
+		totals.demECV, totals.gopECV, totals.tossupECV, strings.Join(leaders, " "))
+	if err != nil {
+		log.Fatalf("recordForecast: sqliteDatabase.Exec failed\n%s\nreason: %s\n", sqlText, err.Error())
+	}
+}
+
+// Read the forecast history of the given ECV algorithm and settings, oldest first.
+func readForecastHistory(algorithm, settings string) []forecastEntry {
+	var entries []forecastEntry
+	sqlText := fmt.Sprintf("SELECT %s, %s, %s, %s, %s FROM %s WHERE %s = ? AND %s = ? ORDER BY %s",
+		colAsOf, colDemECV, colGopECV, colTossupECV, colLeaders, tableForecast, colAlgorithm, colSettings, colAsOf)
+	rows, err := sqliteDatabase.Query(sqlText, algorithm, settings)
+	if err != nil {
+		log.Fatalf("readForecastHistory: sqliteDatabase.Query failed\n%s\nreason: %s\n", sqlText, err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var asOfString string
+		var entry forecastEntry
+		err := rows.Scan(&asOfString, &entry.demECV, &entry.gopECV, &entry.tossupECV, &entry.leaders)
+		if err != nil {
+			log.Fatalf("readForecastHistory: rows.Scan failed, reason: %s\n", err.Error())
+		}
+		entry.asOf, err = YYYY_MM_DDtoTime(asOfString)
+		if err != nil {
+			log.Fatalf("readForecastHistory: Cannot parse as-of date: %s, reason: %s\n", asOfString, err.Error())
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// Plot the Dem, Gop, and Tossup EVs over time from the forecast history of the current algorithm and settings.
+func plotForecastHistory() {
+	glob := global.GetGlobalRef()
+	opts := currentOptions()
+	entries := readForecastHistory(opts.algorithm.Name(), forecastSettings(opts))
+	if len(entries) < 1 {
+		log.Println("plotForecastHistory: no forecast history, no EC timeline plot")
+		return
+	}
+
+	plt := plot.New()
+	plt.Title.Text = fmt.Sprintf("Electoral College over Time (%s)", opts.algorithm.Name())
+	plt.X.Tick.Marker = plot.TimeTicks{Format: "Jan 02"}
+	if entries[len(entries)-1].asOf.Sub(entries[0].asOf) > 300*24*time.Hour {
+		plt.X.Tick.Marker = plot.TimeTicks{Format: "Jan 2006"}
+	}
+	plt.Y.Label.Text = "Electoral votes"
+	plt.Y.Min, plt.Y.Max = 0.0, float64(global.EV_TO_WIN)*1.25
+	plt.Add(plotter.NewGrid())
+
+	// The line to win.
+	first := float64(entries[0].asOf.Unix())
+	last := float64(entries[len(entries)-1].asOf.Unix())
+	if last <= first {
+		last = first + 24.0*60.0*60.0
+	}
+	winLine, err := plotter.NewLine(plotter.XYs{{X: first, Y: float64(global.EV_TO_WIN)}, {X: last, Y: float64(global.EV_TO_WIN)}})
+	if err != nil {
+		log.Fatalf("plotForecastHistory: plotter.NewLine failed, reason: %s\n", err.Error())
+	}
+	winLine.Color = color.Black
+	winLine.Dashes = []vg.Length{vg.Points(4), vg.Points(3)}
+	plt.Add(winLine)
+
+	addSeries := func(name string, votes func(entry forecastEntry) int, leader string) {
+		pts := make(plotter.XYs, len(entries))
+		for ix, entry := range entries {
+			pts[ix].X = float64(entry.asOf.Unix())
+			pts[ix].Y = float64(votes(entry))
+		}
+		line, points, err := plotter.NewLinePoints(pts)
+		if err != nil {
+			log.Fatalf("plotForecastHistory: plotter.NewLinePoints(%s) failed, reason: %s\n", name, err.Error())
+		}
+		colour := mapColour(stateResult{leader: leader, aveDemPct: mapMarginFull})
+		line.Color = colour
+		line.Width = 2
+		points.Shape = draw.CircleGlyph{}
+		points.Color = colour
+		plt.Add(line, points)
+		plt.Legend.Add(name, line)
+	}
+	addSeries("Dem", func(entry forecastEntry) int { return entry.demECV }, "Dem")
+	addSeries("Gop", func(entry forecastEntry) int { return entry.gopECV }, "Gop")
+	addSeries("Tossup", func(entry forecastEntry) int { return entry.tossupECV }, "TOSSUP")
+	plt.Legend.Top = true
+
+	savePlot(plt, 2.0*glob.PlotWidth, glob.PlotHeight, "ectimeline", global.DummyTime, glob.PlotFormats)
+	log.Printf("EC timeline: %d as-of dates\n", len(entries))
+}
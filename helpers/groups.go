@@ -0,0 +1,67 @@
+package helpers
+
+import (
+	"fmt"
+	"ppolls2024/global"
+)
+
+// Is the given state selected by the -b and -g command-line filters?
+func stateSelected(stcode string) bool {
+	glob := global.GetGlobalRef()
+	if glob.FlagBattleground && !searchSlice(glob.Battleground, stcode) {
+		return false
+	}
+	if glob.GroupFilter != nil && !searchSlice(glob.GroupFilter, stcode) {
+		return false
+	}
+	return true
+}
+
+// Show the EV subtotals and average margin of one group of states.
+func showGroup(name string, states []string, byState map[string]stateResult) {
+	var members []stateResult
+	for _, stcode := range states {
+		members = append(members, byState[stcode])
+	}
+	totals := tallyEC(members)
+	totalECV := totals.demECV + totals.gopECV + totals.tossupECV
+
+	// EV-weighted average margin over the states that have polls.
+	sumMargin := 0.0
+	sumVotes := 0
+	for _, member := range members {
+		if member.pollCount > 0 {
+			sumMargin += float64(member.entry.Votes) * member.margin()
+			sumVotes += member.entry.Votes
+		}
+	}
+	marginString := "    --"
+	if sumVotes > 0 {
+		marginString = fmt.Sprintf("%+6.1f", sumMargin/float64(sumVotes))
+	}
+	fmt.Printf("%-16s  %6d  %3d  %3d  %3d  %6d  %s\n",
+		name, len(members), totalECV, totals.demECV, totals.gopECV, totals.tossupECV, marginString)
+}
+
+// ReportGroups - EV subtotals and average margin for the built-in and user-defined state groups.
+func ReportGroups() {
+	glob := global.GetGlobalRef()
+	byState := make(map[string]stateResult)
+	for _, result := range computeEC(currentOptions()) {
+		byState[result.entry.Stcode] = result
+	}
+	prtDivider := "----------------------------------------------------------"
+	fmt.Println("\nGroup             States   EV  Dem  Gop  Tossup  Margin")
+	fmt.Println(prtDivider)
+	showGroup("Battleground", glob.Battleground, byState)
+	showGroup("StronglyDem", glob.StronglyDem, byState)
+	showGroup("StronglyGop", glob.StronglyGop, byState)
+	if len(glob.Groups) > 0 {
+		fmt.Println(prtDivider)
+	}
+	for _, group := range glob.Groups {
+		showGroup(group.Name, group.States, byState)
+	}
+	fmt.Println(prtDivider)
+	fmt.Println("Margin: EV-weighted average of Dem - Gop over the group's states that have polls.")
+}
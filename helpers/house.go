@@ -0,0 +1,176 @@
+package helpers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"ppolls2024/global"
+	"sort"
+	"time"
+)
+
+// House effect of one pollster.
+type houseEffect struct {
+	pollster string  // Pollster name
+	count    int     // Number of polls that had a reference average
+	lean     float64 // Average lean in points (positive = leans Dem, negative = leans Gop)
+	stdDev   float64 // Standard deviation of the lean
+}
+
+// One poll as needed for the house effect computation.
+type housePoll struct {
+	state    string
+	endDate  time.Time
+	margin   float64
+	pollster string
+}
+
+/*
+Compute the house effect of every pollster.
+
+For each poll, the reference is the average margin (Dem - Gop) of the polls of the same state by other pollsters
+whose end dates are within HouseEffectWindow days of the poll's end date.
+The poll's lean is its margin minus the reference. Polls without a reference are not counted.
+A pollster's house effect is the average lean of its polls.
+
+Only the polls that end on or after dateThreshold and not after asOf (DummyTime = no limit) are used,
+so that a computation as of a past date does not learn from later polls.
+With byLoad, asOf applies to the date that the poll was first loaded instead of its end date.
+*/
+func computeHouseEffects(asOf, dateThreshold time.Time, byLoad bool) []houseEffect {
+	glob := global.GetGlobalRef()
+	var polls []housePoll
+	var query dbparams
+	var dateStamp string
+
+	rows := sqlQuery("SELECT date_stamp, state, end_date, pct_dem, pct_gop, pollster FROM history ORDER BY state, end_date")
+	defer rows.Close()
+	for rows.Next() {
+		err := rows.Scan(&dateStamp, &query.state, &query.endDate, &query.pctDem, &query.pctGop, &query.pollster)
+		if err != nil {
+			log.Fatalf("computeHouseEffects: rows.Scan failed, reason: %s\n", err.Error())
+		}
+		tm, err := YYYY_MM_DDtoTime(query.endDate)
+		if err != nil {
+			log.Fatalf("computeHouseEffects: Cannot parse end date: %s, reason: %s\n", query.endDate, err.Error())
+		}
+		if tm.Before(dateThreshold) {
+			continue
+		}
+		if asOf != global.DummyTime {
+			tmAsOf := tm
+			if byLoad {
+				tmAsOf, err = YYYY_MM_DDtoTime(dateStamp)
+				if err != nil {
+					log.Fatalf("computeHouseEffects: Cannot parse load date: %s, reason: %s\n", dateStamp, err.Error())
+				}
+			}
+			if tmAsOf.After(asOf) {
+				continue
+			}
+		}
+		polls = append(polls, housePoll{state: query.state, endDate: tm, margin: query.pctDem - query.pctGop, pollster: query.pollster})
+	}
+
+	// Collect the leans of each pollster.
+	leans := houseLeans(polls, time.Duration(glob.HouseEffectWin)*24*time.Hour)
+
+	// Average and standard deviation per pollster.
+	var effects []houseEffect
+	for pollster, array := range leans {
+		mean := 0.0
+		for _, lean := range array {
+			mean += lean
+		}
+		mean /= float64(len(array))
+		stdDev := 0.0
+		if len(array) > 1 {
+			for _, lean := range array {
+				stdDev += (lean - mean) * (lean - mean)
+			}
+			stdDev = math.Sqrt(stdDev / float64(len(array)-1))
+		}
+		effects = append(effects, houseEffect{pollster: pollster, count: len(array), lean: mean, stdDev: stdDev})
+	}
+	sort.Slice(effects, func(ii, jj int) bool {
+		if effects[ii].count != effects[jj].count {
+			return effects[ii].count > effects[jj].count
+		}
+		return effects[ii].pollster < effects[jj].pollster
+	})
+	return effects
+}
+
+/*
+Compute the lean of each poll that has a reference: its margin minus the average margin of the polls
+of the same state by other pollsters whose end dates are within the window of its end date.
+Returns the leans by pollster.
+*/
+func houseLeans(polls []housePoll, window time.Duration) map[string][]float64 {
+	byState := make(map[string][]housePoll)
+	for _, poll := range polls {
+		byState[poll.state] = append(byState[poll.state], poll)
+	}
+	leans := make(map[string][]float64)
+	for _, statePolls := range byState {
+		sort.SliceStable(statePolls, func(ii, jj int) bool { return statePolls[ii].endDate.Before(statePolls[jj].endDate) })
+		first := 0 // First poll that can be within the window of the current poll
+		for _, poll := range statePolls {
+			for poll.endDate.Sub(statePolls[first].endDate) > window {
+				first++
+			}
+			sum := 0.0
+			count := 0
+			for _, other := range statePolls[first:] {
+				if other.endDate.Sub(poll.endDate) > window {
+					break
+				}
+				if other.pollster == poll.pollster {
+					continue
+				}
+				sum += other.margin
+				count++
+			}
+			if count > 0 {
+				leans[poll.pollster] = append(leans[poll.pollster], poll.margin-sum/float64(count))
+			}
+		}
+	}
+	return leans
+}
+
+// Map each pollster with at least HouseEffectMinPolls polls to its house effect (lean), as of opts.asOf.
+func houseEffectMap(opts ecOptions) map[string]float64 {
+	glob := global.GetGlobalRef()
+	effectMap := make(map[string]float64)
+	for _, effect := range computeHouseEffects(opts.asOf, opts.dateThreshold, opts.byLoad) {
+		if effect.count >= glob.HouseEffectMin {
+			effectMap[effect.pollster] = effect.lean
+		}
+	}
+	return effectMap
+}
+
+// ReportHouse - Pollster house effect report.
+func ReportHouse() {
+	glob := global.GetGlobalRef()
+	effects := computeHouseEffects(global.DummyTime, glob.DateThreshold, false)
+	prtDivider := "------------------------------------------------------------"
+	fmt.Printf("\nHouse effects: lean relative to other pollsters' polls of the same state within %d days\n", glob.HouseEffectWin)
+	fmt.Printf("%-30s  %5s  %6s  %6s\n", "Pollster", "Polls", "Lean", "StdDev")
+	fmt.Println(prtDivider)
+	for _, effect := range effects {
+		marker := ""
+		if effect.count < glob.HouseEffectMin {
+			marker = "  *"
+		}
+		fmt.Printf("%-30s  %5d  %+6.1f  %6.1f%s\n", effect.pollster, effect.count, effect.lean, effect.stdDev, marker)
+	}
+	fmt.Println(prtDivider)
+	if len(effects) < 1 {
+		fmt.Println("no data")
+		return
+	}
+	fmt.Println("Lean: positive leans Dem, negative leans Gop (margin points).")
+	fmt.Printf("* Fewer than %d polls: not adjusted by --house-adjust.\n", glob.HouseEffectMin)
+}
@@ -0,0 +1,80 @@
+package helpers
+
+import (
+	"math"
+	"ppolls2024/global"
+	"testing"
+	"time"
+)
+
+func TestComputeHouseEffects(t *testing.T) {
+	setTestGlobals()
+	DBOpen("sqlite", t.TempDir(), "test.db")
+	defer DBClose()
+
+	// PA: pollster A is 4 points more Dem than B, and 6 more than C. OH: A alone, so no reference.
+	for _, poll := range []struct {
+		state    string
+		day      int
+		pctDem   float64
+		pctGop   float64
+		pollster string
+	}{
+		{"PA", 1, 50.0, 46.0, "A"},
+		{"PA", 2, 48.0, 48.0, "B"},
+		{"PA", 3, 50.0, 46.0, "A"},
+		{"PA", 4, 47.0, 49.0, "C"},
+		{"OH", 2, 45.0, 50.0, "A"},
+	} {
+		endDate := testDay(poll.day).Format("2006-01-02")
+		DBStore(dbparams{state: poll.state, startDate: endDate, endDate: endDate, pctDem: poll.pctDem, pctGop: poll.pctGop,
+			pollster: poll.pollster})
+	}
+
+	type lean struct {
+		count int
+		lean  float64
+	}
+	tests := []struct {
+		name          string
+		asOf          time.Time
+		dateThreshold time.Time
+		want          map[string]lean
+	}{
+		{"all polls", global.DummyTime, global.DummyTime,
+			map[string]lean{"A": {2, 5.0}, "B": {1, -2.0}, "C": {1, -2.0 - 8.0/3.0}}},
+		{"as of day 3", testDay(3), global.DummyTime,
+			map[string]lean{"A": {2, 4.0}, "B": {1, -4.0}}},
+		{"from day 2", global.DummyTime, testDay(2),
+			map[string]lean{"A": {1, 5.0}, "B": {1, -1.0}, "C": {1, -4.0}}},
+	}
+	for _, tt := range tests {
+		effects := computeHouseEffects(tt.asOf, tt.dateThreshold, false)
+		if len(effects) != len(tt.want) {
+			t.Errorf("%s: %d pollsters, want %d: %+v", tt.name, len(effects), len(tt.want), effects)
+			continue
+		}
+		for _, effect := range effects {
+			want, ok := tt.want[effect.pollster]
+			if !ok || effect.count != want.count || math.Abs(effect.lean-want.lean) > 1e-9 {
+				t.Errorf("%s: %s: %d polls, lean %+.3f, want %d polls, lean %+.3f", tt.name, effect.pollster,
+					effect.count, effect.lean, want.count, want.lean)
+			}
+		}
+	}
+}
+
+func TestHouseLeansWindow(t *testing.T) {
+	polls := []housePoll{
+		{state: "PA", endDate: testDay(1), margin: 2.0, pollster: "A"},
+		{state: "PA", endDate: testDay(20), margin: -2.0, pollster: "B"},
+		{state: "PA", endDate: testDay(25), margin: 0.0, pollster: "C"},
+	}
+	leans := houseLeans(polls, 14*24*time.Hour)
+	if len(leans["A"]) != 0 {
+		t.Errorf("houseLeans: A has leans %v, want none (no other poll within the window)", leans["A"])
+	}
+	if len(leans["B"]) != 1 || leans["B"][0] != -2.0 || len(leans["C"]) != 1 || leans["C"][0] != 2.0 {
+		t.Errorf("houseLeans: B %v C %v, want [-2] and [2]", leans["B"], leans["C"])
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"ppolls2024/global"
 	"strconv"
 	"strings"
 )
 
 func Load(dirCsv, fileName string) {
+	glob := global.GetGlobalRef()
 	var pollTable []string
 	var pollFields dbparams
 
@@ -46,7 +48,7 @@ func Load(dirCsv, fileName string) {
 		}
 
 		// Collect all the column values.
-		pollFields.state = strings.ToUpper(colArray[0])
+		pollFields.state = NormalizeStcode(colArray[0])
 		pollFields.pctDem, err = strconv.ParseFloat(colArray[1], 64)
 		if err != nil {
 			log.Fatalf("Load: Dem pct from %s is not a valid float at line %d\n", fullPath, lineCounter)
@@ -63,7 +65,7 @@ func Load(dirCsv, fileName string) {
 		if err != nil {
 			log.Fatalf("Load: start day from %s is not a valid integer at line %d\n", fullPath, lineCounter)
 		}
-		pollFields.startDate = fmt.Sprintf("2024-%02d-%02d", month, day)
+		pollFields.startDate = fmt.Sprintf("%d-%02d-%02d", glob.Cycle, month, day)
 		month, err = MonthToInt(colArray[6])
 		if err != nil {
 			log.Fatalf("Load: end month from %s at line %d: %s\n", fullPath, lineCounter, err.Error())
@@ -72,7 +74,17 @@ func Load(dirCsv, fileName string) {
 		if err != nil {
 			log.Fatalf("Load: end day from %s is not a valid integer at line %d\n", fullPath, lineCounter)
 		}
-		pollFields.endDate = fmt.Sprintf("2024-%02d-%02d", month, day)
+		pollFields.endDate = fmt.Sprintf("%d-%02d-%02d", glob.Cycle, month, day)
+		// An optional trailing "n=<sample size>" column follows the pollster name.
+		pollFields.sampleSize = 0
+		lastCol := colArray[len(colArray)-1]
+		if len(colArray) > 9 && strings.HasPrefix(strings.ToLower(lastCol), "n=") {
+			pollFields.sampleSize, err = strconv.Atoi(lastCol[2:])
+			if err != nil || pollFields.sampleSize < 1 {
+				log.Fatalf("Load: sample size (%s) from %s is not a valid positive integer at line %d\n", lastCol, fullPath, lineCounter)
+			}
+			colArray = colArray[:len(colArray)-1]
+		}
 		pollFields.pollster = strings.Join(colArray[8:], " ")
 
 		// Insert this database row.
@@ -80,4 +92,7 @@ func Load(dirCsv, fileName string) {
 	}
 
 	log.Printf("Loaded %d records into the database\n", lineCounter)
+
+	// Refresh the smoothed series.
+	StoreSmoothed()
 }
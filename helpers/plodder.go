@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"image/color"
 	"log"
+	"math"
 	"ppolls2024/global"
 	"time"
 
@@ -19,7 +20,7 @@ import (
 	"gonum.org/v1/plot/vg/draw"
 )
 
-func plotOneState(state string, endDateArray []string, demPctArray, gopPctArray, otherPctArray []float64) int {
+func plotOneState(state string, endDateArray []string, demPctArray, gopPctArray, otherPctArray []float64, series []smoothPoint) int {
 	glob := global.GetGlobalRef()
 	RED := color.NRGBA{R: 255, A: 255}
 	//GREEN := color.NRGBA{G: 255, A: 255}
@@ -37,9 +38,8 @@ func plotOneState(state string, endDateArray []string, demPctArray, gopPctArray,
 	countPoints := 0
 
 	linePoints := func(dateArray []string, dependent []float64) plotter.XYs {
-		arraySizes := len(dateArray)
-		pts := make(plotter.XYs, arraySizes)
-		for ix := range pts {
+		var pts plotter.XYs
+		for ix := range dateArray {
 			layout := string(time.RFC3339[:10])
 			tm, err := time.Parse(layout, dateArray[ix])
 			if err != nil {
@@ -50,8 +50,7 @@ func plotOneState(state string, endDateArray []string, demPctArray, gopPctArray,
 			}
 			countPoints++
 			timeInt64 := time.Date(tm.Year(), tm.Month(), tm.Day(), 12, 30, 30, 0, time.UTC).Unix()
-			pts[ix].X = float64(timeInt64)
-			pts[ix].Y = dependent[ix]
+			pts = append(pts, plotter.XY{X: float64(timeInt64), Y: dependent[ix]})
 		}
 		return pts
 	}
@@ -63,55 +62,180 @@ func plotOneState(state string, endDateArray []string, demPctArray, gopPctArray,
 
 	log.Printf("State plot for %s .....\n", state)
 
-	line, points, err := plotter.NewLinePoints(data)
-	if err != nil {
-		log.Fatalf("plotOneState: internal error diagnosed in plotter.NewLinePoints(dem), reason: %s\n" + err.Error())
+	// Without a smoothed series, join the polls point to point.
+	// With a smoothed series, show the polls as points and the series as lines.
+	addSeries := func(name string, data plotter.XYs, smooth plotter.XYs, colour color.Color) {
+		if smooth == nil {
+			line, points, err := plotter.NewLinePoints(data)
+			if err != nil {
+				log.Fatalf("plotOneState: internal error diagnosed in plotter.NewLinePoints(%s), reason: %s\n", name, err.Error())
+			}
+			line.Color = colour
+			line.Width = 2
+			points.Shape = draw.CircleGlyph{}
+			points.Color = BLACK
+			plt.Add(line, points)
+			return
+		}
+		points, err := plotter.NewScatter(data)
+		if err != nil {
+			log.Fatalf("plotOneState: internal error diagnosed in plotter.NewScatter(%s), reason: %s\n", name, err.Error())
+		}
+		points.Shape = draw.CircleGlyph{}
+		points.Color = colour
+		line, err := plotter.NewLine(smooth)
+		if err != nil {
+			log.Fatalf("plotOneState: internal error diagnosed in plotter.NewLine(%s), reason: %s\n", name, err.Error())
+		}
+		line.Color = colour
+		line.Width = 2
+		plt.Add(line, points)
 	}
-	line.Color = BLUE
-	line.Width = 2
-	points.Shape = draw.CircleGlyph{}
-	points.Color = BLACK
-	plt.Add(line, points)
 
-	data = linePoints(endDateArray, gopPctArray)
-	line, points, err = plotter.NewLinePoints(data)
-	if err != nil {
-		log.Fatalf("plotOneState: internal error diagnosed in plotter.NewLinePoints(gop), reason: %s\n" + err.Error())
+	// Smoothed series points.
+	smoothPoints := func(value func(point smoothPoint) float64) plotter.XYs {
+		if len(series) < 1 {
+			return nil
+		}
+		pts := make(plotter.XYs, len(series))
+		for ix, point := range series {
+			pts[ix].X = float64(time.Date(point.date.Year(), point.date.Month(), point.date.Day(), 12, 30, 30, 0, time.UTC).Unix())
+			pts[ix].Y = value(point)
+		}
+		return pts
+	}
+
+	addSeries("dem", data, smoothPoints(func(point smoothPoint) float64 { return point.pctDem }), BLUE)
+	addSeries("gop", linePoints(endDateArray, gopPctArray),
+		smoothPoints(func(point smoothPoint) float64 { return point.pctGop }), RED)
+	addSeries("other", linePoints(endDateArray, otherPctArray),
+		smoothPoints(func(point smoothPoint) float64 { return CalcOther(point.pctDem, point.pctGop) }), GREY)
+
+	if len(series) > 0 {
+		plt.Title.Text = fmt.Sprintf("%s Polling (%s)", state, glob.Smoother)
+	}
+
+	savePlot(plt, glob.PlotWidth, glob.PlotHeight, state, global.DummyTime, glob.PlotFormats)
+
+	return 1 // We generated a plot for the current state.
+}
+
+/*
+Plot the margin (Dem - Gop) of one state over time: the polls as points sized by sample size,
+the band of +/- TossupThreshold around the zero line shaded (labelled as such, since only the threshold
+algorithms call exactly that band a tossup; none for margin-of-error), and the smoothed margin (if any) as a line.
+*/
+func plotStateMargin(state string, endDateArray []string, marginArray []float64, sampleSizeArray []int, series []smoothPoint) {
+	glob := global.GetGlobalRef()
+
+	var pts plotter.XYs
+	var radii []vg.Length
+	for ix := range endDateArray {
+		tm, err := YYYY_MM_DDtoTime(endDateArray[ix])
+		if err != nil {
+			log.Fatalf("plotStateMargin: Cannot parse end date: %s, reason: %s\n", endDateArray[ix], err.Error())
+		}
+		if tm.Before(glob.DateThreshold) {
+			continue
+		}
+		pts = append(pts, plotter.XY{X: float64(time.Date(tm.Year(), tm.Month(), tm.Day(), 12, 30, 30, 0, time.UTC).Unix()), Y: marginArray[ix]})
+
+		// Point area proportional to the sample size.
+		sampleSize := sampleSizeArray[ix]
+		if sampleSize < 1 {
+			sampleSize = glob.DefSampleSize
+		}
+		radius := 3.0 * math.Sqrt(float64(sampleSize)/float64(glob.DefSampleSize))
+		radii = append(radii, vg.Points(math.Max(1.5, math.Min(radius, 8.0))))
+	}
+	if len(pts) < 1 {
+		return
+	}
+
+	plt := plot.New()
+	plt.Title.Text = fmt.Sprintf("%s Margin (Dem - Gop)", state)
+	plt.X.Tick.Marker = plot.TimeTicks{Format: "Jan 02"}
+	plt.Y.Label.Text = "Points"
+	plt.Add(plotter.NewGrid())
+
+	// Time span of the plot.
+	first, last := pts[0].X, pts[0].X
+	for _, pt := range pts {
+		first = math.Min(first, pt.X)
+		last = math.Max(last, pt.X)
+	}
+	if last <= first {
+		last = first + 24.0*60.0*60.0
 	}
-	line.Color = RED
-	line.Width = 2
-	points.Shape = draw.CircleGlyph{}
-	points.Color = BLACK
-	plt.Add(line, points)
 
-	data = linePoints(endDateArray, otherPctArray)
-	line, points, err = plotter.NewLinePoints(data)
+	// TossupThreshold band and zero line.
+	if _, ok := LookupAlgorithm(glob.ECVAlgorithm).(marginOfErrorAlgorithm); !ok {
+		band, err := plotter.NewPolygon(plotter.XYs{{X: first, Y: -glob.TossupThreshold}, {X: last, Y: -glob.TossupThreshold},
+			{X: last, Y: glob.TossupThreshold}, {X: first, Y: glob.TossupThreshold}})
+		if err != nil {
+			log.Fatalf("plotStateMargin: plotter.NewPolygon(%s) failed, reason: %s\n", state, err.Error())
+		}
+		band.Color = color.NRGBA{R: 190, G: 170, B: 200, A: 128}
+		band.LineStyle.Width = 0
+		plt.Add(band)
+		plt.Y.Label.Text = fmt.Sprintf("Points (shaded: TossupThreshold +/-%.1f)", glob.TossupThreshold)
+	}
+	zeroLine, err := plotter.NewLine(plotter.XYs{{X: first, Y: 0.0}, {X: last, Y: 0.0}})
 	if err != nil {
-		log.Fatalf("plotOneState: internal error diagnosed in plotter.NewLinePoints(other), reason: %s\n" + err.Error())
+		log.Fatalf("plotStateMargin: plotter.NewLine(%s) failed, reason: %s\n", state, err.Error())
 	}
-	line.Color = GREY
-	line.Width = 2
-	points.Shape = draw.CircleGlyph{}
-	points.Color = BLACK
-	plt.Add(line, points)
+	zeroLine.Color = color.Black
+	plt.Add(zeroLine)
 
-	err = plt.Save(vg.Length(glob.PlotWidth)*vg.Centimeter,
-		vg.Length(glob.PlotHeight)*vg.Centimeter,
-		fmt.Sprintf("%s/%s.png", glob.DirPlots, state))
+	// Smoothed margin.
+	if len(series) > 0 {
+		smooth := make(plotter.XYs, len(series))
+		for ix, point := range series {
+			smooth[ix].X = float64(time.Date(point.date.Year(), point.date.Month(), point.date.Day(), 12, 30, 30, 0, time.UTC).Unix())
+			smooth[ix].Y = point.pctDem - point.pctGop
+		}
+		line, err := plotter.NewLine(smooth)
+		if err != nil {
+			log.Fatalf("plotStateMargin: plotter.NewLine(%s) failed, reason: %s\n", state, err.Error())
+		}
+		line.Width = 2
+		plt.Add(line)
+		plt.Title.Text = fmt.Sprintf("%s Margin (Dem - Gop, %s)", state, glob.Smoother)
+	}
+
+	// Polls: blue above the zero line, red below.
+	points, err := plotter.NewScatter(pts)
 	if err != nil {
-		log.Fatalln(err.Error())
+		log.Fatalf("plotStateMargin: plotter.NewScatter(%s) failed, reason: %s\n", state, err.Error())
+	}
+	points.GlyphStyleFunc = func(ix int) draw.GlyphStyle {
+		colour := color.NRGBA{R: 255, A: 255}
+		if pts[ix].Y > 0.0 {
+			colour = color.NRGBA{B: 255, A: 255}
+		}
+		return draw.GlyphStyle{Color: colour, Radius: radii[ix], Shape: draw.CircleGlyph{}}
 	}
+	plt.Add(points)
 
-	return 1 // We generated a plot for the current state.
+	savePlot(plt, glob.PlotWidth, glob.PlotHeight, state+"_margin", global.DummyTime, glob.PlotFormats)
 }
 
 func Plodder() {
 	glob := global.GetGlobalRef()
 	var stateTableEntry global.StateTableEntry_t
 	counterStates := 0
+
+	// Refresh the smoothed series.
+	if glob.Smoother != "none" {
+		StoreSmoothed()
+	}
+
 	for _, stateTableEntry = range global.StateTable {
+		if glob.GroupFilter != nil && !searchSlice(glob.GroupFilter, stateTableEntry.Stcode) {
+			continue
+		}
 		// For the given state, query from the most recent to the least recent polling.
-		sqlText := fmt.Sprintf("SELECT end_date, pct_dem, pct_gop FROM history WHERE state = '%s' ORDER BY end_date DESC",
+		sqlText := fmt.Sprintf("SELECT end_date, pct_dem, pct_gop, sample_size FROM history WHERE state = '%s' ORDER BY end_date DESC",
 			stateTableEntry.Stcode)
 		rows := sqlQuery(sqlText)
 
@@ -120,25 +244,60 @@ func Plodder() {
 		var demPctArray []float64
 		var gopPctArray []float64
 		var otherPctArray []float64
+		var marginDateArray []string
+		var marginArray []float64
+		var sampleSizeArray []int
 		counterRows := 0
 		for rows.Next() {
-			counterRows += 1
-			err := rows.Scan(&query.endDate, &query.pctDem, &query.pctGop)
+			err := rows.Scan(&query.endDate, &query.pctDem, &query.pctGop, &query.sampleSize)
 			if err != nil {
 				log.Fatalf("Plodder: rows.Scan failed, row count: %d, reason: %s\n", counterRows, err.Error())
 			}
+			// The margin plot shows all polls (since DateThreshold).
+			marginDateArray = append(marginDateArray, query.endDate)
+			marginArray = append(marginArray, query.pctDem-query.pctGop)
+			sampleSizeArray = append(sampleSizeArray, query.sampleSize)
+			// With a smoother, all polls are plotted; else the last PollHistoryLimit polls.
+			if glob.Smoother == "none" && counterRows >= glob.PollHistoryLimit {
+				continue
+			}
+			counterRows += 1
 			endDateArray = append(endDateArray, query.endDate)
 			demPctArray = append(demPctArray, query.pctDem)
 			gopPctArray = append(gopPctArray, query.pctGop)
 			curOtherPct := CalcOther(query.pctDem, query.pctGop)
 			otherPctArray = append(otherPctArray, curOtherPct)
-			if counterRows >= glob.PollHistoryLimit {
-				break
-			}
 		}
+		rows.Close()
 		if counterRows > 0 {
-			counterStates += plotOneState(stateTableEntry.Stcode, endDateArray, demPctArray, gopPctArray, otherPctArray)
+			series := readSmoothed(stateTableEntry.Stcode)
+			counterStates += plotOneState(stateTableEntry.Stcode, endDateArray, demPctArray, gopPctArray, otherPctArray, series)
+			plotStateMargin(stateTableEntry.Stcode, marginDateArray, marginArray, sampleSizeArray, series)
 		}
 	}
 	log.Printf("State plots completed: %d\n", counterStates)
+
+	// The Electoral College computation as of now, shared by the plots below.
+	opts := currentOptions()
+	results := computeEC(opts)
+
+	// Polling coverage heat map.
+	plotCoverage(results)
+
+	// Electoral College map now and as of each --map-dates date.
+	plotECMap(opts, results)
+	for _, asOf := range glob.MapDates {
+		optsAsOf := currentOptions()
+		optsAsOf.asOf = asOf
+		plotECMap(optsAsOf, computeEC(optsAsOf))
+	}
+
+	// Electoral College tile-grid cartogram.
+	plotECTiles(results)
+
+	// Electoral College summary bar.
+	plotECBar(results)
+
+	// Electoral College over time, from the forecast history that includes the maps above.
+	plotForecastHistory()
 }
@@ -0,0 +1,109 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"ppolls2024/global"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+// PlotFormatNames - Plot file formats (file extensions) that PlotFormats and --plot-formats can name.
+// png, jpg, and tif are drawn at PlotDPI; svg, pdf, and eps are vector formats.
+var PlotFormatNames = []string{"png", "jpg", "tif", "svg", "pdf", "eps"}
+
+// ParsePlotFormats - Parse a comma-separated list of plot file formats.
+func ParsePlotFormats(list string) ([]string, error) {
+	var formats []string
+	for _, item := range strings.Split(list, ",") {
+		format := strings.ToLower(strings.TrimSpace(item))
+		if !searchSlice(PlotFormatNames, format) {
+			return nil, fmt.Errorf("plot format (%s) is not one of: %s", item, strings.Join(PlotFormatNames, ", "))
+		}
+		if !searchSlice(formats, format) {
+			formats = append(formats, format)
+		}
+	}
+	return formats, nil
+}
+
+// The configured plot formats plus the given ones.
+func plotFormatsWith(always ...string) []string {
+	glob := global.GetGlobalRef()
+	formats := append([]string{}, glob.PlotFormats...)
+	for _, format := range always {
+		if !searchSlice(formats, format) {
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+/*
+Plot file path (without extension) from the PlotFileName template:
+{name} is the plot name (E.g. a state code or "ecmap"), {date} is the as-of date (DummyTime = today),
+and {cycle} is the election year.
+*/
+func plotFilePath(name string, asOf time.Time) string {
+	glob := global.GetGlobalRef()
+	if asOf == global.DummyTime {
+		asOf = time.Now().UTC()
+	}
+	fileName := strings.ReplaceAll(glob.PlotFileName, "{name}", name)
+	fileName = strings.ReplaceAll(fileName, "{date}", asOf.Format("2006-01-02"))
+	fileName = strings.ReplaceAll(fileName, "{cycle}", fmt.Sprintf("%d", glob.Cycle))
+	return fmt.Sprintf("%s/%s", glob.DirPlots, fileName)
+}
+
+/*
+Save a plot of the given width and height (centimetres) in each of the given formats,
+named per the PlotFileName template. Raster formats are drawn at PlotDPI.
+*/
+func savePlot(plt *plot.Plot, width, height float64, name string, asOf time.Time, formats []string) {
+	glob := global.GetGlobalRef()
+	ww := vg.Length(width) * vg.Centimeter
+	hh := vg.Length(height) * vg.Centimeter
+	path := plotFilePath(name, asOf)
+	for _, format := range formats {
+		pathFile := path + "." + format
+		var writer io.WriterTo
+		switch format {
+		case "png", "jpg", "tif":
+			canvas := vgimg.NewWith(vgimg.UseWH(ww, hh), vgimg.UseDPI(glob.PlotDPI))
+			plt.Draw(draw.New(canvas))
+			switch format {
+			case "png":
+				writer = vgimg.PngCanvas{Canvas: canvas}
+			case "jpg":
+				writer = vgimg.JpegCanvas{Canvas: canvas}
+			default:
+				writer = vgimg.TiffCanvas{Canvas: canvas}
+			}
+		default:
+			var err error
+			writer, err = plt.WriterTo(ww, hh, format)
+			if err != nil {
+				log.Fatalf("savePlot: plt.WriterTo(%s) failed, reason: %s\n", pathFile, err.Error())
+			}
+		}
+		outHandle, err := os.Create(pathFile)
+		if err != nil {
+			log.Fatalf("savePlot: os.Create(%s) failed, reason: %s\n", pathFile, err.Error())
+		}
+		_, err = writer.WriteTo(outHandle)
+		if err != nil {
+			log.Fatalf("savePlot: WriteTo(%s) failed, reason: %s\n", pathFile, err.Error())
+		}
+		err = outHandle.Close()
+		if err != nil {
+			log.Fatalf("savePlot: Close(%s) failed, reason: %s\n", pathFile, err.Error())
+		}
+	}
+}
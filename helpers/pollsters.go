@@ -0,0 +1,127 @@
+package helpers
+
+import (
+	"fmt"
+	"log"
+	"ppolls2024/global"
+	"sort"
+	"strings"
+)
+
+// Inventory of one pollster.
+type pollsterEntry struct {
+	pollster  string
+	count     int             // Number of polls
+	states    map[string]bool // States covered
+	firstDate string          // End date of the oldest poll
+	lastDate  string          // End date of the newest poll
+	sumMargin float64         // Sum of Dem - Gop over the polls
+	inWindow  int             // Number of polls among the last PollHistoryLimit polls of a state
+}
+
+// Average margin (Dem - Gop) of the pollster's polls.
+func (pe *pollsterEntry) margin() float64 {
+	return pe.sumMargin / float64(pe.count)
+}
+
+// PollsterSortKeys - Sort keys of -r pollsters (--sort).
+var PollsterSortKeys = []string{"polls", "states", "first", "last", "margin", "window", "name"}
+
+// Sort the pollster inventory by the given key; ties are broken by name.
+func sortPollsters(entries []*pollsterEntry, key string) {
+	sort.SliceStable(entries, func(ii, jj int) bool {
+		aa, bb := entries[ii], entries[jj]
+		switch key {
+		case "polls":
+			if aa.count != bb.count {
+				return aa.count > bb.count
+			}
+		case "states":
+			if len(aa.states) != len(bb.states) {
+				return len(aa.states) > len(bb.states)
+			}
+		case "first":
+			if aa.firstDate != bb.firstDate {
+				return aa.firstDate < bb.firstDate
+			}
+		case "last":
+			if aa.lastDate != bb.lastDate {
+				return aa.lastDate > bb.lastDate
+			}
+		case "margin":
+			if aa.margin() != bb.margin() {
+				return aa.margin() > bb.margin()
+			}
+		case "window":
+			if aa.inWindow != bb.inWindow {
+				return aa.inWindow > bb.inWindow
+			}
+		}
+		return strings.ToLower(aa.pollster) < strings.ToLower(bb.pollster)
+	})
+}
+
+// ReportPollsters - Inventory of every pollster in the history table.
+func ReportPollsters() {
+	glob := global.GetGlobalRef()
+	byName := make(map[string]*pollsterEntry)
+	var entries []*pollsterEntry
+
+	// All polls in the history table.
+	rows := sqlQuery("SELECT state, end_date, pct_dem, pct_gop, pollster FROM history")
+	var query dbparams
+	counterPolls := 0
+	for rows.Next() {
+		err := rows.Scan(&query.state, &query.endDate, &query.pctDem, &query.pctGop, &query.pollster)
+		if err != nil {
+			log.Fatalf("ReportPollsters: rows.Scan failed, row count: %d, reason: %s\n", counterPolls, err.Error())
+		}
+		counterPolls++
+		entry, ok := byName[query.pollster]
+		if !ok {
+			entry = &pollsterEntry{pollster: query.pollster, states: make(map[string]bool),
+				firstDate: query.endDate, lastDate: query.endDate}
+			byName[query.pollster] = entry
+			entries = append(entries, entry)
+		}
+		entry.count++
+		entry.states[query.state] = true
+		if query.endDate < entry.firstDate {
+			entry.firstDate = query.endDate
+		}
+		if query.endDate > entry.lastDate {
+			entry.lastDate = query.endDate
+		}
+		entry.sumMargin += query.pctDem - query.pctGop
+	}
+	rows.Close()
+	if counterPolls < 1 {
+		fmt.Println("\nno data")
+		return
+	}
+
+	// Which pollsters make up the current averages?
+	counterWindow := 0
+	for _, result := range computeEC(currentOptions()) {
+		for _, poll := range result.polls[:result.pollCount] {
+			if entry, ok := byName[poll.pollster]; ok {
+				entry.inWindow++
+				counterWindow++
+			}
+		}
+	}
+
+	sortPollsters(entries, glob.PollsterSort)
+	prtDivider := "------------------------------------------------------------------------------------"
+	fmt.Printf("\nPollsters: %d, polls: %d, sorted by %s\n", len(entries), counterPolls, glob.PollsterSort)
+	fmt.Printf("%-30s  %5s  %6s  %-10s  %-10s  %6s  %6s\n", "Pollster", "Polls", "States", "First", "Last", "Margin", "Window")
+	fmt.Println(prtDivider)
+	for _, entry := range entries {
+		fmt.Printf("%-30s  %5d  %6d  %-10s  %-10s  %+6.1f  %6d\n", entry.pollster, entry.count, len(entry.states),
+			entry.firstDate, entry.lastDate, entry.margin(), entry.inWindow)
+	}
+	fmt.Println(prtDivider)
+	fmt.Println("Margin: average Dem - Gop over all of the pollster's polls (positive leans Dem).")
+	fmt.Printf("Window: polls among the last %d polls of a state that make up the current averages (%d in all).\n",
+		glob.PollHistoryLimit, counterWindow)
+}
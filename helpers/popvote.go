@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"fmt"
+	"log"
+	"ppolls2024/global"
+)
+
+/*
+ReportPV - Implied national popular vote.
+
+Each state's polling average is weighted by the votes cast there in the previous election (turnout table).
+A state without polls uses its PriorFallback averages ("prior"), or else its previous election percentages (the baseline).
+Congressional districts are skipped because their votes are already counted statewide.
+*/
+func ReportPV() {
+	totalVotes := 0.0
+	sumDem := 0.0
+	sumGop := 0.0
+	polledVotes := 0.0
+	prtDivider := "--------------------------------------------------"
+	fmt.Println("\nSt     Turnout  Source     Dem    Gop    Other")
+	fmt.Println(prtDivider)
+	for _, result := range computeEC(currentOptions()) {
+		if result.entry.Parent != "" {
+			continue
+		}
+		turnout := global.TurnoutTableLookup(result.entry.Stcode)
+		if turnout == nil {
+			log.Fatalf("ReportPV: state %s is missing from the turnout table\n", result.entry.Stcode)
+		}
+		source := "polls"
+		pctDem := result.aveDemPct
+		pctGop := result.aveGopPct
+		votes := float64(turnout.Votes)
+		if result.prior {
+			source = "prior"
+		} else if result.pollCount < 1 {
+			source = "baseline"
+			pctDem = turnout.PctDem
+			pctGop = turnout.PctGop
+		} else {
+			polledVotes += votes
+		}
+		totalVotes += votes
+		sumDem += votes * pctDem
+		sumGop += votes * pctGop
+		fmt.Printf("%-5s %9d  %-8s  %5.1f  %5.1f  %5.1f\n",
+			result.entry.Stcode, turnout.Votes, source, pctDem, pctGop, CalcOther(pctDem, pctGop))
+	}
+	fmt.Println(prtDivider)
+	if totalVotes <= 0.0 {
+		fmt.Println("no data")
+		return
+	}
+	pctDem := sumDem / totalVotes
+	pctGop := sumGop / totalVotes
+	fmt.Printf("National popular vote estimate: Dem %.1f, Gop %.1f, Other %.1f, margin %+.1f\n",
+		pctDem, pctGop, CalcOther(pctDem, pctGop), pctDem-pctGop)
+	fmt.Printf("Share of expected turnout covered by polls: %.1f%%\n", 100.0*polledVotes/totalVotes)
+}
@@ -3,188 +3,295 @@ package helpers
 import (
 	"fmt"
 	"log"
+	"math"
 	"ppolls2024/global"
+	"sort"
+	"strings"
 )
 
-func ReportSC(state string) {
+// Maximum number of states listed as most in need of fresh polling in -r ec.
+const needsPollingMax = 10
+
+// ReportSC - Detailed poll report for one or more comma-separated state codes and/or group names.
+func ReportSC(ids string) {
+	for _, state := range ResolveStates(ids) {
+		reportOneState(state)
+	}
+}
+
+// Detailed poll report for one state, subject to the command-line filters.
+func reportOneState(state string) {
 	glob := global.GetGlobalRef()
 	// Query record
 	var query dbparams
 
+	// Polls ending before this date are not shown.
+	dateFrom := glob.DateThreshold
+	if glob.FilterFrom != global.DummyTime {
+		dateFrom = glob.FilterFrom
+	}
+
 	// For the given state, query from the most recent to the least recent polling.
-	sqlText := fmt.Sprintf("SELECT state, end_date, pct_dem, pct_gop, pollster FROM history WHERE state = '%s' ORDER BY end_date DESC", state)
+	sqlText := fmt.Sprintf("SELECT state, end_date, pct_dem, pct_gop, pollster, sample_size FROM history WHERE state = '%s' ORDER BY end_date DESC", state)
 
 	// Get all the selected history table rows.
 	counterRows := 0
+	counterShown := 0
 	log.Printf("State report: %s\n", state)
 	rows := sqlQuery(sqlText)
-	fmt.Printf("%-8s    %-4s  %-4s  %-4s %-s\n", "EndPoll", "Dem", "Gop", "Other", "Pollster")
+	defer rows.Close()
+	fmt.Printf("\n%s (%d EV)\n", state, StateToECV(state))
+	fmt.Printf("%-8s    %-4s  %-4s  %-4s %5s  %-s\n", "EndPoll", "Dem", "Gop", "Other", "N", "Pollster")
 
 	// For each row, process it...
 	for rows.Next() {
 		counterRows += 1
-		err := rows.Scan(&query.state, &query.endDate, &query.pctDem, &query.pctGop, &query.pollster)
+		err := rows.Scan(&query.state, &query.endDate, &query.pctDem, &query.pctGop, &query.pollster, &query.sampleSize)
 		if err != nil {
 			log.Fatalf("ReportSC: rows.Scan failed, row count: %d, reason: %s\n", counterRows, err.Error())
 		}
 		tm, err := YYYY_MM_DDtoTime(query.endDate)
 		if err != nil {
-			log.Fatalf("ReportEC: Cannot parse start date: %s, reason: %s\n\n", query.endDate, err.Error())
+			log.Fatalf("ReportSC: Cannot parse start date: %s, reason: %s\n\n", query.endDate, err.Error())
+		}
+		if tm.Before(dateFrom) {
+			continue
+		}
+		if glob.FilterTo != global.DummyTime && tm.After(glob.FilterTo) {
+			continue
+		}
+		if glob.FilterPollster != "" && !strings.Contains(strings.ToLower(query.pollster), strings.ToLower(glob.FilterPollster)) {
+			continue
 		}
-		if tm.Before(glob.DateThreshold) {
+		if query.sampleSize < glob.FilterMinSample {
 			continue
 		}
+		counterShown += 1
 		other := CalcOther(query.pctDem, query.pctGop)
-		fmt.Printf("%-8s  %4.1f  %4.1f  %4.1f  %-s\n", query.endDate, query.pctDem, query.pctGop, other, query.pollster)
-		if counterRows >= glob.PollHistoryLimit {
+		sampleSize := "?"
+		if query.sampleSize > 0 {
+			sampleSize = fmt.Sprintf("%d", query.sampleSize)
+		}
+		fmt.Printf("%-8s  %4.1f  %4.1f  %4.1f  %5s  %-s\n", query.endDate, query.pctDem, query.pctGop, other, sampleSize, query.pollster)
+		if !glob.FlagAll && counterShown >= glob.PollHistoryLimit {
 			break
 		}
 	}
-	if counterRows < 1 {
+	if counterShown < 1 {
 		fmt.Println("no data")
+		return
+	}
+
+	// Trends over all eligible polls, regardless of the filters.
+	opts := withHouseEffects(currentOptions())
+	result := computeState(*StateTableLookup(state), opts)
+	if opts.smoother != "" {
+		fmt.Printf("Smoothed (%s) estimate as of the newest poll: Dem %4.1f  Gop %4.1f  Margin %+5.1f\n",
+			opts.smoother, result.aveDemPct, result.aveGopPct, result.margin())
+	}
+	fmt.Printf("Trend (points per week over the last %d days of polls, * = significant): Dem %s  Gop %s  Other %s\n",
+		glob.TrendWindow, result.demTrend, result.gopTrend, result.otherTrend)
+	if result.bootstrap.valid {
+		fmt.Printf("%.0f%% bootstrap intervals of the average of %d polls: Dem %s  Gop %s  Margin %s\n",
+			100.0*glob.ConfidenceLevel, result.pollCount, result.bootstrap.demCI.pctString(),
+			result.bootstrap.gopCI.pctString(), result.bootstrap.marginCI)
 	}
 }
 
-func ReportEC() {
+// The leader of a state as shown in -r ec: a stale call is downgraded to a lean if StaleAction is "lean".
+func showLeader(result stateResult) string {
 	glob := global.GetGlobalRef()
-	var stateTableEntry global.StateTableEntry_t
-	totalDemECV := 0
-	totalGopECV := 0
-	totalTossupECV := 0
-	counterDemStates := 0
-	counterGopStates := 0
-	counterTossupStates := 0
-	listDemStates := ""
-	listGopStates := ""
-	listTossupStates := ""
-	var arrayDemPct []float64
-	var arrayGopPct []float64
-	var arrayOtherPct []float64
-	prtDivider := "------------------------------------------------------------"
-	fmt.Println("\nSt   EV  Last Poll   Dem       Gop       Other       Leading")
-	fmt.Println(prtDivider)
-	for _, stateTableEntry = range global.StateTable {
-		if glob.FlagBattleground {
-			if !searchSlice(glob.Battleground, stateTableEntry.Stcode) {
-				continue
-			}
+	if result.stale && glob.StaleAction == "lean" && result.leader != "TOSSUP" {
+		return "Lean " + result.leader
+	}
+	return result.leader
+}
+
+// Show the stale and unpolled states that most need fresh polling: closest margin first, then most EV.
+func showNeedsPolling(results []stateResult) {
+	var needs []stateResult
+	for _, result := range results {
+		if result.stale || result.pollCount < 1 {
+			needs = append(needs, result)
 		}
-		// For the given state, query from the most recent to the least recent polling.
-		sqlText := fmt.Sprintf("SELECT end_date, pct_dem, pct_gop FROM history WHERE state = '%s' ORDER BY end_date DESC",
-			stateTableEntry.Stcode)
-		rows := sqlQuery(sqlText)
-
-		counterRows := 0
-		var query dbparams
-		aveDemPct := 0.0
-		aveGopPct := 0.0
-		aveOtherPct := 0.0
-		endDate := ""
-		for rows.Next() {
-			err := rows.Scan(&query.endDate, &query.pctDem, &query.pctGop)
-			if err != nil {
-				log.Fatalf("ReportEC: rows.Scan failed, row count: %d, reason: %s\n", counterRows, err.Error())
-			}
-			tm, err := YYYY_MM_DDtoTime(query.endDate)
-			if err != nil {
-				log.Fatalf("ReportEC: Cannot parse start date: %s, reason: %s\n\n", query.endDate, err.Error())
-			}
-			if tm.Before(glob.DateThreshold) {
-				continue
-			}
-			counterRows += 1
+	}
+	if len(needs) < 1 {
+		fmt.Println("\nNo state needs fresh polling.")
+		return
+	}
+	sort.SliceStable(needs, func(ii, jj int) bool {
+		marginI := math.Abs(needs[ii].margin())
+		marginJ := math.Abs(needs[jj].margin())
+		if marginI != marginJ {
+			return marginI < marginJ
+		}
+		return needs[ii].entry.Votes > needs[jj].entry.Votes
+	})
+	if len(needs) > needsPollingMax {
+		needs = needs[:needsPollingMax]
+	}
+	fmt.Println("\nMost in need of fresh polling:")
+	fmt.Println("St     EV   Age  Margin  Source")
+	for _, result := range needs {
+		ageString := "--"
+		source := "stale"
+		if result.ageDays >= 0 {
+			ageString = fmt.Sprintf("%d", result.ageDays)
+		} else if result.prior {
+			source = "prior"
+		} else if result.viaParent {
+			source = "via " + result.entry.Parent
+		} else {
+			source = "no data"
+		}
+		marginString := fmt.Sprintf("%+6.1f", result.margin())
+		if result.pollCount < 1 && !result.prior && !result.viaParent {
+			marginString = "    --"
+		}
+		fmt.Printf("%-4s  %3d  %4s  %s  %s\n", result.entry.Stcode, result.entry.Votes, ageString, marginString, source)
+	}
+}
 
-			// If first row, that is the end date.
-			if counterRows == 1 {
-				endDate = query.endDate
-			}
+func ReportEC() {
+	glob := global.GetGlobalRef()
+	var reported []stateResult
+	var reportedScenario []stateResult
+	opts := currentOptions()
+	baseline := computeEC(opts)
+	recordForecast(global.DummyTime, opts, baseline)
 
-			aveDemPct += query.pctDem
-			arrayDemPct = append(arrayDemPct, query.pctDem)
-			aveGopPct += query.pctGop
-			arrayGopPct = append(arrayGopPct, query.pctGop)
-			arrayOtherPct = append(arrayOtherPct, CalcOther(query.pctDem, query.pctGop))
+	// What-if scenario?
+	var scenario scenarioStruct
+	var scenarioResults []stateResult
+	scenarioActive := glob.ScenarioFile != ""
+	if scenarioActive {
+		scenario = loadScenario(glob.ScenarioFile)
+		scenarioResults = applyScenario(scenario, baseline, opts)
+	}
 
-			// Don't go over the poll history threshold.
-			if counterRows >= glob.PollHistoryLimit {
-				break
-			}
+	prtDivider := "---------------------------------------------------------------------------------"
+	if glob.FlagHouseAdjust {
+		fmt.Println("\nPoll percentages are adjusted for pollster house effects.")
+	}
+	header := "St     EV  Last Poll    Age   Dem   Trend   Gop   Trend   Other Trend     "
+	if glob.BootstrapSamples > 0 {
+		prtDivider += "-------------------------------------------"
+		header += "Dem CI        Gop CI        Margin CI      "
+	}
+	header += "Leading"
+	if scenarioActive {
+		prtDivider += "-----------"
+		header += "   Scenario"
+		fmt.Printf("\nScenario: %s\n", scenario.Name)
+	} else {
+		fmt.Println()
+	}
+	fmt.Println(header)
+	fmt.Println(prtDivider)
+	for ix, result := range baseline {
+		if !stateSelected(result.entry.Stcode) {
+			continue
 		}
+		reported = append(reported, result)
 
-		// Got any data for this state?
-		if counterRows < 1 { // NO DATA
-			endDate = "no data   "
-			var strongly bool
-			strongly = searchSlice(glob.StronglyDem, stateTableEntry.Stcode)
-			if strongly { // Strongly Democrat
-				aveDemPct = 99.9
-				aveGopPct = 0.0
-				aveOtherPct = 0.0
-			} else {
-				strongly = searchSlice(glob.StronglyGop, stateTableEntry.Stcode)
-				if strongly { // Strongly GOP
-					aveDemPct = 0.0
-					aveGopPct = 99.9
-					aveOtherPct = 0.0
-				} else { // Battleground
-					aveDemPct = 0.0
-					aveGopPct = 0.0
-					aveOtherPct = 99.9
-				}
+		// Show results for current state.
+		ageString := "--"
+		if result.ageDays >= 0 {
+			ageString = fmt.Sprintf("%d", result.ageDays)
+		}
+		staleMarker := " "
+		if result.stale {
+			staleMarker = "!"
+		}
+		fmt.Printf("%-4s  %3d  %-8s  %4s%s  %4.1f  %s  %4.1f  %s  %4.1f  %s%4s  ",
+			result.entry.Stcode, result.entry.Votes, result.endDate, ageString, staleMarker, result.aveDemPct, result.demTrend,
+			result.aveGopPct, result.gopTrend, result.aveOtherPct, result.otherTrend, result.otherFactor)
+		if glob.BootstrapSamples > 0 {
+			demString, gopString, marginString := "--", "--", "--"
+			if result.bootstrap.valid {
+				demString = result.bootstrap.demCI.pctString()
+				gopString = result.bootstrap.gopCI.pctString()
+				marginString = result.bootstrap.marginCI.String()
 			}
-		} else { // We have data for this state.
-			// Averages for this state.
-			aveDemPct /= float64(counterRows)
-			aveGopPct /= float64(counterRows)
-			aveOtherPct = CalcOther(aveDemPct, aveGopPct)
-		}
-
-		// Compute leader and the increments.
-		var leader string
-		var increDem, increGop, increTossup int
-		otherFactor := ""
-		switch glob.ECVAlgorithm {
-		case 1:
-			leader, increDem, increGop, increTossup = ECVAward1(stateTableEntry.Votes, aveDemPct, aveGopPct)
-		case 2:
-			leader, increDem, increGop, increTossup, otherFactor = ECVAward2(stateTableEntry.Votes, aveDemPct, aveGopPct)
-		case 3:
-			leader, increDem, increGop, increTossup, otherFactor = ECVAward3(stateTableEntry.Votes, aveDemPct, aveGopPct)
-		default:
-			log.Fatalf("ReportEC: global.ECVAlgoithm %d is not supported\n", glob.ECVAlgorithm)
-		}
-
-		totalDemECV += increDem
-		totalGopECV += increGop
-		totalTossupECV += increTossup
-		switch leader {
-		case "Dem":
-			counterDemStates++
-			listDemStates += " " + stateTableEntry.Stcode
-		case "Gop":
-			counterGopStates++
-			listGopStates += " " + stateTableEntry.Stcode
-		default:
-			counterTossupStates++
-			listTossupStates += " " + stateTableEntry.Stcode
+			fmt.Printf("%-12s  %-12s  %-13s  ", demString, gopString, marginString)
 		}
-
-		// Show results for current state.
-		demTrend := CalcTrend(arrayDemPct)
-		gopTrend := CalcTrend(arrayGopPct)
-		otherTrend := CalcTrend(arrayOtherPct)
-		fmt.Printf("%-2s  %3d  %-8s  %4.1f  %s  %4.1f  %s  %4.1f  %2s%2s  %-s\n",
-			stateTableEntry.Stcode, stateTableEntry.Votes, endDate, aveDemPct, demTrend,
-			aveGopPct, gopTrend, aveOtherPct, otherTrend, otherFactor, leader)
-
-	} // for _, stateTableEntry = range global.StateTable
+		if !scenarioActive {
+			fmt.Println(showLeader(result))
+			continue
+		}
+		reportedScenario = append(reportedScenario, scenarioResults[ix])
+		changed := ""
+		if scenarioResults[ix].leader != result.leader {
+			changed = " *"
+		}
+		fmt.Printf("%-8s  %s%s\n", showLeader(result), scenarioResults[ix].leader, changed)
+	}
 
 	// Totals.
+	totals := tallyEC(reported)
 	fmt.Println(prtDivider)
-	if glob.ECVAlgorithm != 1 {
-		fmt.Println("** The Other percentage exceeds the difference between Dem and Gop.")
+	fmt.Printf("ECV algorithm: %s\n", opts.algorithm.Name())
+	if legend := opts.algorithm.FactorLegend(awardSettings(opts)); legend != "" {
+		fmt.Println(legend)
+	}
+	if _, ok := opts.algorithm.(marginOfErrorAlgorithm); ok {
+		counterPolls, counterDefaulted := 0, 0
+		for _, result := range reported {
+			for _, sampleSize := range result.sampleSizes {
+				counterPolls++
+				if sampleSize < 1 {
+					counterDefaulted++
+				}
+			}
+		}
+		fmt.Printf("Sample sizes: %d of %d averaged polls report none; DefaultSampleSize (%d) is assumed for them.\n",
+			counterDefaulted, counterPolls, glob.DefSampleSize)
+	}
+	fmt.Printf("Trend: points per week over the last %d days of polls, * = significant.\n", glob.TrendWindow)
+	if opts.smoother != "" {
+		fmt.Printf("Dem, Gop, Other: %s smoothed estimate over all polls as of the newest poll.\n", opts.smoother)
+	}
+	if glob.BootstrapSamples > 0 {
+		fmt.Printf("Dem CI, Gop CI, Margin CI: %.0f%% bootstrap intervals of Dem, Gop, and Dem - Gop (%d resamples of the averaged polls).\n",
+			100.0*glob.ConfidenceLevel, glob.BootstrapSamples)
+		if opts.bootstrapTossup {
+			fmt.Println("A state whose margin interval includes 0 is a tossup.")
+		}
+	}
+	for _, result := range reported {
+		if result.viaParent {
+			fmt.Println("via ST: District without polls; the average of its parent state is used.")
+			break
+		}
+	}
+	switch opts.priorFallback {
+	case "prior":
+		fmt.Println("prior: No polls; the previous cycle's result is used.")
+	case "swing":
+		swing, counter := nationalSwing(baseline)
+		fmt.Printf("prior: No polls; the previous cycle's result is used, shifted by the national swing of %+.1f (%d polled states).\n",
+			swing, counter)
+	}
+	fmt.Printf("Dem    EV: %3d, states: (%2d)%s\n", totals.demECV, totals.counterDemStates, totals.listDemStates)
+	fmt.Printf("Gop    EV: %3d, states: (%2d)%s\n", totals.gopECV, totals.counterGopStates, totals.listGopStates)
+	fmt.Printf("Tossup EV: %3d, states: (%2d)%s\n", totals.tossupECV, totals.counterTossupStates, totals.listTossupStates)
+	if glob.StaleDays > 0 {
+		fmt.Printf("Stale  EV: %3d, states: (%2d)%s\n", totals.staleECV, totals.counterStaleStates, totals.listStaleStates)
+		if glob.StaleAction == "lean" {
+			fmt.Printf("           Lean Dem %d of the Dem EV, Lean Gop %d of the Gop EV.\n", totals.staleDemECV, totals.staleGopECV)
+		}
+		fmt.Printf("Age: days from the state's newest poll to today, ! = stale (over %d days).\n", glob.StaleDays)
+		showNeedsPolling(reported)
+	}
+
+	// Baseline versus scenario.
+	if scenarioActive {
+		totalsScenario := tallyEC(reportedScenario)
+		fmt.Printf("\n* The scenario changes the leader.\n")
+		fmt.Printf("\nScenario: %s\n", scenario.Name)
+		fmt.Println("           Baseline  Scenario  Change")
+		fmt.Printf("Dem    EV:   %3d       %3d     %+4d\n", totals.demECV, totalsScenario.demECV, totalsScenario.demECV-totals.demECV)
+		fmt.Printf("Gop    EV:   %3d       %3d     %+4d\n", totals.gopECV, totalsScenario.gopECV, totalsScenario.gopECV-totals.gopECV)
+		fmt.Printf("Tossup EV:   %3d       %3d     %+4d\n", totals.tossupECV, totalsScenario.tossupECV, totalsScenario.tossupECV-totals.tossupECV)
 	}
-	fmt.Printf("Dem    EV: %3d, states: (%2d)%s\n", totalDemECV, counterDemStates, listDemStates)
-	fmt.Printf("Gop    EV: %3d, states: (%2d)%s\n", totalGopECV, counterGopStates, listGopStates)
-	fmt.Printf("Tossup EV: %3d, states: (%2d)%s\n", totalTossupECV, counterTossupStates, listTossupStates)
 }
@@ -0,0 +1,106 @@
+package helpers
+
+import (
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+	"strings"
+)
+
+/*
+What-if scenario definition, loaded from a YAML file. For example:
+
+	Name: PA goes the other way
+	Force:
+	  PA: Gop
+	Shift:
+	  GA: -2.5
+	  NC: 1.0
+
+Force awards a state to "Dem", "Gop", or "Tossup" regardless of its polling.
+Shift moves a state's margin (Dem - Gop) by the given number of points before the award algorithm runs:
+positive values favour Dem, negative values favour Gop.
+*/
+type scenarioStruct struct {
+	Name  string             `yaml:"Name"`
+	Force map[string]string  `yaml:"Force"`
+	Shift map[string]float64 `yaml:"Shift"`
+}
+
+// Load and validate a scenario file.
+func loadScenario(pathScenario string) scenarioStruct {
+	var scenario scenarioStruct
+	bytes, err := os.ReadFile(pathScenario)
+	if err != nil {
+		log.Fatalf("loadScenario: os.ReadFile(%s) failed, reason: %s\n", pathScenario, err.Error())
+	}
+	err = yaml.Unmarshal(bytes, &scenario)
+	if err != nil {
+		log.Fatalf("loadScenario: yaml.Unmarshal from %s failed, reason: %s\n", pathScenario, err.Error())
+	}
+	if scenario.Name == "" {
+		scenario.Name = pathScenario
+	}
+
+	// Normalise and validate the state codes and candidates.
+	force := make(map[string]string)
+	for state, candidate := range scenario.Force {
+		state = NormalizeStcode(state)
+		StateToECV(state) // validate the state code
+		switch strings.ToUpper(candidate) {
+		case "DEM":
+			force[state] = "Dem"
+		case "GOP":
+			force[state] = "Gop"
+		case "TOSSUP":
+			force[state] = "TOSSUP"
+		default:
+			log.Fatalf("loadScenario: %s Force for %s must be Dem, Gop, or Tossup, not %s\n", pathScenario, state, candidate)
+		}
+	}
+	scenario.Force = force
+	shift := make(map[string]float64)
+	for state, points := range scenario.Shift {
+		state = NormalizeStcode(state)
+		StateToECV(state) // validate the state code
+		shift[state] = points
+	}
+	scenario.Shift = shift
+
+	log.Printf("loadScenario: %s: %d forced, %d shifted\n", scenario.Name, len(scenario.Force), len(scenario.Shift))
+	return scenario
+}
+
+// Apply a scenario to a copy of the baseline state results.
+func applyScenario(scenario scenarioStruct, baseline []stateResult, opts ecOptions) []stateResult {
+	results := make([]stateResult, len(baseline))
+	copy(results, baseline)
+	for ii := range results {
+		result := &results[ii]
+		stcode := result.entry.Stcode
+		if points, ok := scenario.Shift[stcode]; ok {
+			result.aveDemPct += points / 2.0
+			result.aveGopPct -= points / 2.0
+			result.bootstrap.demCI.low += points / 2.0
+			result.bootstrap.demCI.high += points / 2.0
+			result.bootstrap.gopCI.low -= points / 2.0
+			result.bootstrap.gopCI.high -= points / 2.0
+			result.bootstrap.marginCI.low += points
+			result.bootstrap.marginCI.high += points
+			awardState(result, opts)
+		}
+		if candidate, ok := scenario.Force[stcode]; ok {
+			result.leader = candidate
+			result.increDem, result.increGop, result.increTossup = 0, 0, 0
+			switch candidate {
+			case "Dem":
+				result.increDem = result.entry.Votes
+			case "Gop":
+				result.increGop = result.entry.Votes
+			default:
+				result.increTossup = result.entry.Votes
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,269 @@
+package helpers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"ppolls2024/global"
+	"sort"
+	"time"
+)
+
+// One day of a smoothed polling series.
+type smoothPoint struct {
+	date   time.Time // Day
+	pctDem float64   // Estimated Dem percentage
+	pctGop float64   // Estimated Gop percentage
+}
+
+// Smoothers that can be named by the Smoother configuration parameter.
+var smootherNames = []string{"none", "loess", "kalman"}
+
+/*
+Compute the daily smoothed series of the given polls (in any order) with the given smoother ("loess" or "kalman").
+The series runs from the day of the oldest poll to the day of the newest poll.
+Returns nil if there are no polls or the smoother is "none".
+*/
+func smoothSeries(polls []statePoll, smoother string) []smoothPoint {
+	if len(polls) < 1 {
+		return nil
+	}
+	ascending := make([]statePoll, len(polls))
+	copy(ascending, polls)
+	sort.SliceStable(ascending, func(ii, jj int) bool { return ascending[ii].endDate.Before(ascending[jj].endDate) })
+	switch smoother {
+	case "loess":
+		return loessSeries(ascending)
+	case "kalman":
+		return kalmanSeries(ascending)
+	}
+	return nil
+}
+
+// Days from the oldest poll to the given date.
+func dayNumber(oldest, date time.Time) float64 {
+	return date.Sub(oldest).Hours() / 24.0
+}
+
+/*
+LOESS: locally weighted linear regression.
+
+	For each day, the polls that ended within LoessSpan days of it get the tricube weight (1 - (distance / span)^3)^3.
+	The estimate is the value at that day of the weighted least squares line through them,
+	or their weighted mean if they all ended on the same day.
+	A day without polls within the span keeps the estimate of the previous day.
+*/
+func loessSeries(polls []statePoll) []smoothPoint {
+	glob := global.GetGlobalRef()
+	span := float64(glob.LoessSpan)
+	oldest := polls[0].endDate
+	newest := polls[len(polls)-1].endDate
+
+	// Local estimate of one candidate's percentage at day xx.
+	estimate := func(xx float64, value func(poll statePoll) float64) (float64, bool) {
+		sumW, sumX, sumY := 0.0, 0.0, 0.0
+		var ww, xs, ys []float64
+		for _, poll := range polls {
+			xi := dayNumber(oldest, poll.endDate)
+			distance := math.Abs(xi - xx)
+			if distance >= span {
+				continue
+			}
+			weight := math.Pow(1.0-math.Pow(distance/span, 3), 3)
+			ww = append(ww, weight)
+			xs = append(xs, xi)
+			ys = append(ys, value(poll))
+			sumW += weight
+			sumX += weight * xi
+			sumY += weight * value(poll)
+		}
+		if sumW <= 0.0 {
+			return 0.0, false
+		}
+		meanX := sumX / sumW
+		meanY := sumY / sumW
+		sxx, sxy := 0.0, 0.0
+		for ii := range ww {
+			sxx += ww[ii] * (xs[ii] - meanX) * (xs[ii] - meanX)
+			sxy += ww[ii] * (xs[ii] - meanX) * (ys[ii] - meanY)
+		}
+		if sxx <= 0.0 {
+			return meanY, true
+		}
+		return meanY + (sxy/sxx)*(xx-meanX), true
+	}
+
+	var series []smoothPoint
+	for day := oldest; !day.After(newest); day = day.AddDate(0, 0, 1) {
+		xx := dayNumber(oldest, day)
+		pctDem, okDem := estimate(xx, func(poll statePoll) float64 { return poll.pctDem })
+		pctGop, okGop := estimate(xx, func(poll statePoll) float64 { return poll.pctGop })
+		if !okDem || !okGop {
+			if len(series) < 1 {
+				continue
+			}
+			pctDem = series[len(series)-1].pctDem
+			pctGop = series[len(series)-1].pctGop
+		}
+		series = append(series, smoothPoint{date: day, pctDem: pctDem, pctGop: pctGop})
+	}
+	return series
+}
+
+/*
+Kalman filter of a local-level model: the true percentage drifts as a random walk.
+
+	Each day the variance of the level grows by KalmanDrift^2.
+	Each poll is a measurement with variance p * (100 - p) / n, where n is its sample size
+	(or DefaultSampleSize if the poll did not report one).
+	The filter only looks backward, so the last day is the current estimate.
+*/
+func kalmanSeries(polls []statePoll) []smoothPoint {
+	glob := global.GetGlobalRef()
+	drift := glob.KalmanDrift * glob.KalmanDrift
+	oldest := polls[0].endDate
+	newest := polls[len(polls)-1].endDate
+
+	// Measurement variance of one percentage.
+	variance := func(pct float64, sampleSize int) float64 {
+		if sampleSize < 1 {
+			sampleSize = glob.DefSampleSize
+		}
+		return math.Max(pct*(100.0-pct), 1.0) / float64(sampleSize)
+	}
+
+	levelDem, levelGop := polls[0].pctDem, polls[0].pctGop
+	varDem, varGop := variance(levelDem, polls[0].sampleSize), variance(levelGop, polls[0].sampleSize)
+	ix := 1
+	var series []smoothPoint
+	for day := oldest; !day.After(newest); day = day.AddDate(0, 0, 1) {
+		if day.After(oldest) {
+			varDem += drift
+			varGop += drift
+		}
+		for ; ix < len(polls) && !polls[ix].endDate.After(day); ix++ {
+			measured := variance(polls[ix].pctDem, polls[ix].sampleSize)
+			gain := varDem / (varDem + measured)
+			levelDem += gain * (polls[ix].pctDem - levelDem)
+			varDem *= 1.0 - gain
+			measured = variance(polls[ix].pctGop, polls[ix].sampleSize)
+			gain = varGop / (varGop + measured)
+			levelGop += gain * (polls[ix].pctGop - levelGop)
+			varGop *= 1.0 - gain
+		}
+		series = append(series, smoothPoint{date: day, pctDem: levelDem, pctGop: levelGop})
+	}
+	return series
+}
+
+/*
+StoreSmoothed - Recompute the smoothed series of every state with the configured Smoother and store them in the smoothed table.
+
+All polls after DateThreshold are smoothed, as loaded (without house effect adjustment).
+*/
+func StoreSmoothed() {
+	glob := global.GetGlobalRef()
+	if glob.Smoother == "none" {
+		return
+	}
+
+	// Collect the polls of each state.
+	pollMap := make(map[string][]statePoll)
+	var query dbparams
+	rows := sqlQuery("SELECT state, end_date, pct_dem, pct_gop, sample_size FROM history ORDER BY state, end_date")
+	for rows.Next() {
+		err := rows.Scan(&query.state, &query.endDate, &query.pctDem, &query.pctGop, &query.sampleSize)
+		if err != nil {
+			log.Fatalf("StoreSmoothed: rows.Scan failed, reason: %s\n", err.Error())
+		}
+		tm, err := YYYY_MM_DDtoTime(query.endDate)
+		if err != nil {
+			log.Fatalf("StoreSmoothed: Cannot parse end date: %s, reason: %s\n", query.endDate, err.Error())
+		}
+		if tm.Before(glob.DateThreshold) {
+			continue
+		}
+		pollMap[query.state] = append(pollMap[query.state], statePoll{endDate: tm, pctDem: query.pctDem, pctGop: query.pctGop,
+			sampleSize: query.sampleSize})
+	}
+	rows.Close()
+
+	tx, err := sqliteDatabase.Begin()
+	if err != nil {
+		log.Fatalf("StoreSmoothed: sqliteDatabase.Begin failed, reason: %s\n", err.Error())
+	}
+	sqlText := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", tableSmoothed, colSmoother)
+	_, err = tx.Exec(sqlText, glob.Smoother)
+	if err != nil {
+		log.Fatalf("StoreSmoothed: tx.Exec failed\n%s\nreason: %s\n", sqlText, err.Error())
+	}
+	sqlText = fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s, %s) VALUES (?, ?, ?, ?, ?)",
+		tableSmoothed, colState, colDate, colPctDem, colPctGop, colSmoother)
+	counterRows := 0
+	for _, stateTableEntry := range global.StateTable {
+		for _, point := range smoothSeries(pollMap[stateTableEntry.Stcode], glob.Smoother) {
+			_, err = tx.Exec(sqlText, stateTableEntry.Stcode, point.date.Format("2006-01-02"), point.pctDem, point.pctGop, glob.Smoother)
+			if err != nil {
+				log.Fatalf("StoreSmoothed: tx.Exec failed\n%s\nreason: %s\n", sqlText, err.Error())
+			}
+			counterRows++
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Fatalf("StoreSmoothed: tx.Commit failed, reason: %s\n", err.Error())
+	}
+	log.Printf("StoreSmoothed: %d %s rows\n", counterRows, glob.Smoother)
+}
+
+// Read the stored smoothed series of one state for the configured smoother, oldest first (nil if Smoother is none).
+func readSmoothed(stcode string) []smoothPoint {
+	glob := global.GetGlobalRef()
+	var series []smoothPoint
+	if glob.Smoother == "none" {
+		return series
+	}
+	sqlText := fmt.Sprintf("SELECT %s, %s, %s FROM %s WHERE %s = ? AND %s = ? ORDER BY %s",
+		colDate, colPctDem, colPctGop, tableSmoothed, colState, colSmoother, colDate)
+	rows, err := sqliteDatabase.Query(sqlText, stcode, glob.Smoother)
+	if err != nil {
+		log.Fatalf("readSmoothed: sqliteDatabase.Query failed\n%s\nreason: %s\n", sqlText, err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dateString string
+		var point smoothPoint
+		err := rows.Scan(&dateString, &point.pctDem, &point.pctGop)
+		if err != nil {
+			log.Fatalf("readSmoothed: rows.Scan failed, reason: %s\n", err.Error())
+		}
+		point.date, err = YYYY_MM_DDtoTime(dateString)
+		if err != nil {
+			log.Fatalf("readSmoothed: Cannot parse date: %s, reason: %s\n", dateString, err.Error())
+		}
+		series = append(series, point)
+	}
+	return series
+}
+
+/*
+Smoothed estimate of one state's percentages as of its newest poll.
+
+The stored series is used when it applies: as of now, without house effect adjustment,
+from DateThreshold, and with the configured Smoother. Otherwise the given polls are smoothed.
+Returns false if there is no estimate.
+*/
+func smoothedEstimate(stcode string, polls []statePoll, opts ecOptions) (smoothPoint, bool) {
+	glob := global.GetGlobalRef()
+	var series []smoothPoint
+	if opts.asOf == global.DummyTime && !opts.houseAdjust && opts.dateThreshold.Equal(glob.DateThreshold) && opts.smoother == glob.Smoother {
+		series = readSmoothed(stcode)
+	}
+	if len(series) < 1 {
+		series = smoothSeries(polls, opts.smoother)
+	}
+	if len(series) < 1 {
+		return smoothPoint{}, false
+	}
+	return series[len(series)-1], true
+}
@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSmoothSeries(t *testing.T) {
+	setTestGlobals()
+
+	// Polls on the line Dem = 40 + day / 2, Gop = 50 - day / 4, most recent first.
+	var linear []statePoll
+	for _, day := range []int{21, 15, 10, 7, 3, 1} {
+		linear = append(linear, statePoll{endDate: testDay(day), pctDem: 40.0 + float64(day)/2.0,
+			pctGop: 50.0 - float64(day)/4.0, sampleSize: 800})
+	}
+	var constant []statePoll
+	for _, day := range []int{20, 12, 4} {
+		constant = append(constant, statePoll{endDate: testDay(day), pctDem: 47.0, pctGop: 45.0})
+	}
+
+	tests := []struct {
+		name     string
+		polls    []statePoll
+		smoother string
+		wantDays int     // Length of the series (0 = nil)
+		wantDem  float64 // Dem estimate on the last day
+		wantGop  float64 // Gop estimate on the last day
+	}{
+		{"no polls", nil, "loess", 0, 0.0, 0.0},
+		{"smoother none", constant, "none", 0, 0.0, 0.0},
+		{"loess constant", constant, "loess", 17, 47.0, 45.0},
+		{"kalman constant", constant, "kalman", 17, 47.0, 45.0},
+		{"loess linear", linear, "loess", 21, 50.5, 44.75},
+	}
+	for _, tt := range tests {
+		series := smoothSeries(tt.polls, tt.smoother)
+		if len(series) != tt.wantDays {
+			t.Errorf("%s: %d days, want %d", tt.name, len(series), tt.wantDays)
+			continue
+		}
+		if len(series) < 1 {
+			continue
+		}
+		if !series[0].date.Equal(tt.polls[len(tt.polls)-1].endDate) {
+			t.Errorf("%s: series starts %s, want the oldest poll", tt.name, series[0].date.Format("2006-01-02"))
+		}
+		last := series[len(series)-1]
+		if math.Abs(last.pctDem-tt.wantDem) > 1e-9 || math.Abs(last.pctGop-tt.wantGop) > 1e-9 {
+			t.Errorf("%s: last day Dem %.3f Gop %.3f, want Dem %.3f Gop %.3f", tt.name, last.pctDem, last.pctGop, tt.wantDem, tt.wantGop)
+		}
+	}
+}
+
+func TestLoessSeriesFollowsLine(t *testing.T) {
+	setTestGlobals()
+	var polls []statePoll
+	for _, day := range []int{1, 4, 8, 13, 17} {
+		polls = append(polls, statePoll{endDate: testDay(day), pctDem: 45.0 + 0.2*float64(day), pctGop: 46.0})
+	}
+	for _, point := range loessSeries(polls) {
+		day := float64(point.date.Day())
+		if math.Abs(point.pctDem-(45.0+0.2*day)) > 1e-9 || math.Abs(point.pctGop-46.0) > 1e-9 {
+			t.Errorf("loessSeries: day %.0f: Dem %.3f Gop %.3f, want Dem %.3f Gop 46.000", day, point.pctDem, point.pctGop, 45.0+0.2*day)
+		}
+	}
+}
+
+func TestKalmanSeries(t *testing.T) {
+	glob := setTestGlobals()
+	polls := []statePoll{
+		{endDate: testDay(1), pctDem: 44.0, pctGop: 48.0, sampleSize: 1000},
+		{endDate: testDay(10), pctDem: 50.0, pctGop: 44.0, sampleSize: 1000},
+	}
+	series := kalmanSeries(polls)
+	if len(series) != 10 {
+		t.Fatalf("kalmanSeries: %d days, want 10", len(series))
+	}
+
+	// Until the second poll, the level stays at the first poll.
+	for _, point := range series[:9] {
+		if point.pctDem != 44.0 || point.pctGop != 48.0 {
+			t.Errorf("kalmanSeries: %s: Dem %.3f Gop %.3f, want Dem 44.0 Gop 48.0",
+				point.date.Format("2006-01-02"), point.pctDem, point.pctGop)
+		}
+	}
+
+	// The second poll pulls the level most of the way toward it, the more so the larger the drift.
+	last := series[9]
+	if last.pctDem <= 47.0 || last.pctDem >= 50.0 || last.pctGop >= 46.0 || last.pctGop <= 44.0 {
+		t.Errorf("kalmanSeries: last day Dem %.3f Gop %.3f, want between the polls and nearer the second", last.pctDem, last.pctGop)
+	}
+	glob.KalmanDrift = 0.05
+	slow := kalmanSeries(polls)[9]
+	if slow.pctDem >= last.pctDem {
+		t.Errorf("kalmanSeries: Dem %.3f with a small drift, want below %.3f", slow.pctDem, last.pctDem)
+	}
+}
@@ -0,0 +1,109 @@
+package helpers
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"ppolls2024/global"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// Position of a state in the tile grid: column from the left, row from the top.
+type tilePosition struct {
+	column, row int
+}
+
+// Tile grid layout (the NPR layout): one equal tile per state, so that small states are as visible as large ones.
+var tileLayout = map[string]tilePosition{
+	"AK": {0, 0}, "ME": {10, 0},
+	"WI": {5, 1}, "VT": {9, 1}, "NH": {10, 1},
+	"WA": {0, 2}, "ID": {1, 2}, "MT": {2, 2}, "ND": {3, 2}, "MN": {4, 2}, "IL": {5, 2}, "MI": {6, 2}, "NY": {8, 2}, "MA": {9, 2},
+	"OR": {0, 3}, "NV": {1, 3}, "WY": {2, 3}, "SD": {3, 3}, "IA": {4, 3}, "IN": {5, 3}, "OH": {6, 3}, "PA": {7, 3}, "NJ": {8, 3}, "CT": {9, 3}, "RI": {10, 3},
+	"CA": {0, 4}, "UT": {1, 4}, "CO": {2, 4}, "NE": {3, 4}, "MO": {4, 4}, "KY": {5, 4}, "WV": {6, 4}, "VA": {7, 4}, "MD": {8, 4}, "DE": {9, 4},
+	"AZ": {1, 5}, "NM": {2, 5}, "KS": {3, 5}, "AR": {4, 5}, "TN": {5, 5}, "NC": {6, 5}, "SC": {7, 5}, "DC": {8, 5},
+	"OK": {3, 6}, "LA": {4, 6}, "MS": {5, 6}, "AL": {6, 6}, "GA": {7, 6},
+	"HI": {0, 7}, "TX": {3, 7}, "FL": {8, 7},
+}
+
+// Grid size
+const tileColumns = 11
+const tileRows = 8
+
+/*
+Draw the Electoral College tile-grid cartogram of the given computation and save it as SVG and PNG.
+
+Each state is one square tile, filled like the EC map and labelled with its code and EV.
+Maine and Nebraska show their statewide result and their total EV.
+*/
+func plotECTiles(results []stateResult) {
+	glob := global.GetGlobalRef()
+	totals := tallyEC(results)
+
+	plt := plot.New()
+	plt.HideAxes()
+	plt.Title.Text = "Electoral College"
+
+	// EV of each state including its districts.
+	stateVotes := make(map[string]int)
+	for _, result := range results {
+		stateVotes[result.entry.Stcode[:2]] += result.entry.Votes
+	}
+
+	var labels plotter.XYLabels
+	var labelColours []color.Color
+	for _, result := range results {
+		position, ok := tileLayout[result.entry.Stcode]
+		if !ok {
+			continue // Maine and Nebraska districts
+		}
+		left := float64(position.column)
+		top := float64(tileRows - position.row)
+		tile, err := plotter.NewPolygon(plotter.XYs{{X: left, Y: top}, {X: left + 1.0, Y: top},
+			{X: left + 1.0, Y: top - 1.0}, {X: left, Y: top - 1.0}})
+		if err != nil {
+			log.Fatalf("plotECTiles: plotter.NewPolygon(%s) failed, reason: %s\n", result.entry.Stcode, err.Error())
+		}
+		fill := mapColour(result)
+		tile.Color = fill
+		tile.LineStyle.Color = color.White
+		tile.LineStyle.Width = vg.Points(2)
+		plt.Add(tile)
+
+		labels.XYs = append(labels.XYs, plotter.XY{X: left + 0.5, Y: top - 0.5})
+		labels.Labels = append(labels.Labels, fmt.Sprintf("%s\n%d", result.entry.Stcode, stateVotes[result.entry.Stcode]))
+
+		// White text on the deeper colours.
+		red, green, blue, _ := fill.RGBA()
+		if (299*red+587*green+114*blue)/1000 < 0x8000 {
+			labelColours = append(labelColours, color.White)
+		} else {
+			labelColours = append(labelColours, color.Black)
+		}
+	}
+	tileLabels, err := plotter.NewLabels(labels)
+	if err != nil {
+		log.Fatalf("plotECTiles: plotter.NewLabels failed, reason: %s\n", err.Error())
+	}
+	for ix := range tileLabels.TextStyle {
+		tileLabels.TextStyle[ix].XAlign = draw.XCenter
+		tileLabels.TextStyle[ix].YAlign = draw.YCenter
+		tileLabels.TextStyle[ix].Font.Size = vg.Points(8)
+		tileLabels.TextStyle[ix].Color = labelColours[ix]
+	}
+	plt.Add(tileLabels)
+
+	// EV totals.
+	addMapLegend(plt, totals)
+
+	// Square tiles.
+	plt.X.Min, plt.X.Max = 0.0, float64(tileColumns)
+	plt.Y.Min, plt.Y.Max = 0.0, float64(tileRows)
+	width := 2.0 * glob.PlotWidth
+	height := width * float64(tileRows) / float64(tileColumns)
+	savePlot(plt, width, height, "ectiles", global.DummyTime, plotFormatsWith("svg", "png"))
+	log.Printf("EC tiles: %d states\n", len(labels.Labels))
+}
@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"fmt"
+	"ppolls2024/global"
+	"sort"
+)
+
+/*
+Show the ordered path to EV_TO_WIN for one candidate.
+
+	sign = +1 for Dem (states ordered from the most Dem margin to the least),
+	       -1 for Gop (states ordered from the most Gop margin to the least).
+
+The tipping-point state is the one that delivers the EV_TO_WIN-th electoral vote.
+*/
+func showTippingPath(candidate string, sign float64, results []stateResult) {
+	path := make([]stateResult, len(results))
+	copy(path, results)
+	sort.SliceStable(path, func(ii, jj int) bool {
+		return sign*path[ii].margin() > sign*path[jj].margin()
+	})
+
+	prtDivider := "------------------------------------------"
+	fmt.Printf("\n%s path to %d:\n", candidate, global.EV_TO_WIN)
+	fmt.Println("Rank  St     EV  CumEV  Margin  Leading")
+	fmt.Println(prtDivider)
+	cumECV := 0
+	var tippingPoint *stateResult
+	for ii := range path {
+		cumECV += path[ii].entry.Votes
+		marker := ""
+		if tippingPoint == nil && cumECV >= global.EV_TO_WIN {
+			tippingPoint = &path[ii]
+			marker = "  <-- tipping point"
+		}
+		fmt.Printf("%4d  %-5s %3d  %5d  %+6.1f  %s%s\n",
+			ii+1, path[ii].entry.Stcode, path[ii].entry.Votes, cumECV, sign*path[ii].margin(), path[ii].leader, marker)
+	}
+	fmt.Println(prtDivider)
+	if tippingPoint == nil {
+		fmt.Printf("%s cannot reach %d EV.\n", candidate, global.EV_TO_WIN)
+		return
+	}
+	needed := sign * tippingPoint.margin()
+	if needed > 0 {
+		fmt.Printf("%s tipping-point state: %s, currently leading by %.1f points.\n",
+			candidate, tippingPoint.entry.Stcode, needed)
+	} else {
+		fmt.Printf("%s tipping-point state: %s, needs to gain %.1f points to lead.\n",
+			candidate, tippingPoint.entry.Stcode, -needed)
+	}
+}
+
+// ReportTP - Tipping-point report for both candidates over all states.
+func ReportTP() {
+	results := computeEC(currentOptions())
+	fmt.Println("\nMargin is the candidate's lead in percentage points (negative = trailing).")
+	showTippingPath("Dem", +1.0, results)
+	showTippingPath("Gop", -1.0, results)
+}
@@ -0,0 +1,118 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"ppolls2024/global"
+	"time"
+
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Trend of one series of poll percentages.
+type trendResult struct {
+	valid       bool    // Were there enough polls to compute a trend?
+	slope       float64 // Percentage points per week
+	significant bool    // Is the slope significantly different from zero?
+}
+
+// Format a trend as "+0.4*" (points per week, "*" = significant) or "  -- " if there is no trend.
+func (tr trendResult) String() string {
+	if !tr.valid {
+		return "  -- "
+	}
+	flag := " "
+	if tr.significant {
+		flag = "*"
+	}
+	return fmt.Sprintf("%+4.1f%s", tr.slope, flag)
+}
+
+/*
+Time-weighted linear regression trend.
+
+	The x values are days relative to the most recent date and y values are percentages.
+	Each point has weight 0.5^(age / TrendHalfLife) where age is the number of days
+	before the most recent date. The weights are scaled so that they sum to the number of points.
+	The slope is converted to percentage points per week.
+	The slope is significant if |slope / standard error| is at least the two-sided Student-t critical value
+	for ConfidenceLevel with n - 2 degrees of freedom (E.g. 12.7 for 3 polls at 0.95, 2.0 for 60 polls).
+
+If there are fewer than 3 points or all points have the same date, there is no trend.
+*/
+func calcTrend(dates []time.Time, values []float64) trendResult {
+	glob := global.GetGlobalRef()
+	num := len(values)
+	if num < 3 {
+		return trendResult{}
+	}
+	newest := dates[0]
+	for _, date := range dates {
+		if date.After(newest) {
+			newest = date
+		}
+	}
+
+	// Weights and weighted means.
+	xx := make([]float64, num)
+	ww := make([]float64, num)
+	sumW := 0.0
+	for ii := range values {
+		xx[ii] = -newest.Sub(dates[ii]).Hours() / 24.0
+		ww[ii] = math.Pow(0.5, -xx[ii]/glob.TrendHalfLife)
+		sumW += ww[ii]
+	}
+	meanX := 0.0
+	meanY := 0.0
+	for ii := range values {
+		ww[ii] *= float64(num) / sumW
+		meanX += ww[ii] * xx[ii]
+		meanY += ww[ii] * values[ii]
+	}
+	meanX /= float64(num)
+	meanY /= float64(num)
+
+	// Weighted least squares.
+	sxx := 0.0
+	sxy := 0.0
+	for ii := range values {
+		sxx += ww[ii] * (xx[ii] - meanX) * (xx[ii] - meanX)
+		sxy += ww[ii] * (xx[ii] - meanX) * (values[ii] - meanY)
+	}
+	if sxx <= 0.0 {
+		return trendResult{}
+	}
+	slope := sxy / sxx
+	intercept := meanY - slope*meanX
+	sse := 0.0
+	for ii := range values {
+		residual := values[ii] - (intercept + slope*xx[ii])
+		sse += ww[ii] * residual * residual
+	}
+	stdErr := math.Sqrt(sse / float64(num-2) / sxx)
+	significant := false
+	if stdErr > 0.0 {
+		tCritical := distuv.StudentsT{Mu: 0.0, Sigma: 1.0, Nu: float64(num - 2)}.Quantile(0.5 + glob.ConfidenceLevel/2.0)
+		significant = math.Abs(slope)/stdErr >= tCritical
+	} else {
+		significant = slope != 0.0
+	}
+	return trendResult{valid: true, slope: 7.0 * slope, significant: significant}
+}
+
+// Compute the Dem, Gop, and Other trends over the polls within TrendWindow days of the most recent poll.
+func stateTrends(polls []statePoll) (trendResult, trendResult, trendResult) {
+	glob := global.GetGlobalRef()
+	var dates []time.Time
+	var arrayDemPct, arrayGopPct, arrayOtherPct []float64
+	for _, poll := range polls { // most recent first
+		if polls[0].endDate.Sub(poll.endDate).Hours()/24.0 > float64(glob.TrendWindow) {
+			break
+		}
+		dates = append(dates, poll.endDate)
+		arrayDemPct = append(arrayDemPct, poll.pctDem)
+		arrayGopPct = append(arrayGopPct, poll.pctGop)
+		arrayOtherPct = append(arrayOtherPct, CalcOther(poll.pctDem, poll.pctGop))
+	}
+	return calcTrend(dates, arrayDemPct), calcTrend(dates, arrayGopPct), calcTrend(dates, arrayOtherPct)
+}
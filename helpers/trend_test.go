@@ -0,0 +1,45 @@
+package helpers
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCalcTrend(t *testing.T) {
+	setTestGlobals()
+	tests := []struct {
+		name            string
+		days            []int
+		values          []float64
+		wantValid       bool
+		wantSlope       float64 // Points per week
+		wantSignificant bool
+	}{
+		{"two polls", []int{2, 1}, []float64{50.0, 49.0}, false, 0.0, false},
+		{"same day", []int{5, 5, 5}, []float64{50.0, 49.0, 48.0}, false, 0.0, false},
+		{"flat", []int{10, 5, 1}, []float64{48.0, 48.0, 48.0}, true, 0.0, false},
+		{"exact line", []int{8, 6, 4, 2}, []float64{51.0, 50.0, 49.0, 48.0}, true, 3.5, true},
+		{"noisy, 3 polls", []int{3, 2, 1}, []float64{50.5, 52.0, 50.0}, true, 1.548, false}, // Weighted toward the newest poll
+	}
+	for _, tt := range tests {
+		dates := make([]time.Time, len(tt.days))
+		for ix, day := range tt.days {
+			dates[ix] = testDay(day)
+		}
+		got := calcTrend(dates, tt.values)
+		if got.valid != tt.wantValid {
+			t.Errorf("%s: valid = %t, want %t", tt.name, got.valid, tt.wantValid)
+			continue
+		}
+		if !got.valid {
+			continue
+		}
+		if math.Abs(got.slope-tt.wantSlope) > 0.01 {
+			t.Errorf("%s: slope = %.3f, want %.3f", tt.name, got.slope, tt.wantSlope)
+		}
+		if got.significant != tt.wantSignificant {
+			t.Errorf("%s: significant = %t, want %t", tt.name, got.significant, tt.wantSignificant)
+		}
+	}
+}
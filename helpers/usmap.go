@@ -0,0 +1,215 @@
+package helpers
+
+import (
+	_ "embed"
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"ppolls2024/global"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// Simplified state outlines: one polygon per line, "ST lon,lat lon,lat ...".
+//
+//go:embed maps/us_states.txt
+var usStatesText string
+
+// Margin (points) at and beyond which a state gets the full colour of its leader.
+const mapMarginFull = 15.0
+
+// One state polygon in map coordinates.
+type mapPolygon struct {
+	stcode string
+	xys    plotter.XYs
+}
+
+// Alaska and Hawaii are drawn as insets in the lower left corner of the map.
+type mapInset struct {
+	centreLon, centreLat float64 // Centre of the state in degrees
+	scale                float64 // Scale relative to the lower 48
+	xx, yy               float64 // Centre of the inset in map coordinates
+}
+
+var mapInsets = map[string]mapInset{
+	"AK": {centreLon: -150.0, centreLat: 63.0, scale: 0.35, xx: -19.0, yy: 26.5},
+	"HI": {centreLon: -157.3, centreLat: 20.6, scale: 1.0, xx: -9.0, yy: 25.5},
+}
+
+/*
+Project longitude and latitude onto the map: an equirectangular projection centred on the lower 48,
+with longitudes shrunk by the cosine of the latitude of the state (so that the insets keep their shape).
+*/
+func mapProject(stcode string, lon, lat float64) plotter.XY {
+	if inset, ok := mapInsets[stcode]; ok {
+		shrink := math.Cos(inset.centreLat * math.Pi / 180.0)
+		return plotter.XY{X: inset.xx + (lon-inset.centreLon)*shrink*inset.scale, Y: inset.yy + (lat-inset.centreLat)*inset.scale}
+	}
+	return plotter.XY{X: (lon + 96.0) * math.Cos(38.0*math.Pi/180.0), Y: lat}
+}
+
+// Parse the embedded state outlines into projected polygons.
+func mapPolygons() []mapPolygon {
+	var polygons []mapPolygon
+	for lineNumber, line := range strings.Split(usStatesText, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) < 1 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		polygon := mapPolygon{stcode: fields[0]}
+		for _, field := range fields[1:] {
+			lonLat := strings.Split(field, ",")
+			if len(lonLat) != 2 {
+				log.Fatalf("mapPolygons: line %d: vertex (%s) is not lon,lat\n", lineNumber+1, field)
+			}
+			lon, err1 := strconv.ParseFloat(lonLat[0], 64)
+			lat, err2 := strconv.ParseFloat(lonLat[1], 64)
+			if err1 != nil || err2 != nil {
+				log.Fatalf("mapPolygons: line %d: vertex (%s) is not numeric\n", lineNumber+1, field)
+			}
+			polygon.xys = append(polygon.xys, mapProject(polygon.stcode, lon, lat))
+		}
+		if len(polygon.xys) < 3 {
+			log.Fatalf("mapPolygons: line %d: state %s has fewer than 3 vertices\n", lineNumber+1, polygon.stcode)
+		}
+		polygons = append(polygons, polygon)
+	}
+	return polygons
+}
+
+/*
+Fill colour of a state: blue for Dem, red for Gop, neutral for a tossup.
+The larger the margin, the deeper the colour, reaching the full colour at mapMarginFull points.
+*/
+func mapColour(result stateResult) color.Color {
+	var full color.NRGBA
+	switch result.leader {
+	case "Dem":
+		full = color.NRGBA{R: 30, G: 60, B: 200, A: 255}
+	case "Gop":
+		full = color.NRGBA{R: 200, G: 30, B: 30, A: 255}
+	default:
+		return color.NRGBA{R: 190, G: 170, B: 200, A: 255}
+	}
+	depth := 0.25 + 0.75*math.Min(math.Abs(result.margin())/mapMarginFull, 1.0)
+	blend := func(channel uint8) uint8 {
+		return uint8(255.0 - depth*(255.0-float64(channel)))
+	}
+	return color.NRGBA{R: blend(full.R), G: blend(full.G), B: blend(full.B), A: 255}
+}
+
+// Legend swatch of the given colour.
+func mapSwatch(colour color.Color) *plotter.Polygon {
+	swatch, err := plotter.NewPolygon(plotter.XYs{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0, Y: 1}})
+	if err != nil {
+		log.Fatalf("mapSwatch: plotter.NewPolygon failed, reason: %s\n", err.Error())
+	}
+	swatch.Color = colour
+	swatch.LineStyle.Width = 0
+	return swatch
+}
+
+// Legend of the EV totals in the lower right corner, with the full colour of each leader.
+func addMapLegend(plt *plot.Plot, totals ecTotals) {
+	plt.Legend.Top = false
+	plt.Legend.Add(fmt.Sprintf("Dem %d EV", totals.demECV), mapSwatch(mapColour(stateResult{leader: "Dem", aveDemPct: mapMarginFull})))
+	plt.Legend.Add(fmt.Sprintf("Gop %d EV", totals.gopECV), mapSwatch(mapColour(stateResult{leader: "Gop", aveGopPct: mapMarginFull})))
+	plt.Legend.Add(fmt.Sprintf("Tossup %d EV", totals.tossupECV), mapSwatch(mapColour(stateResult{leader: "TOSSUP"})))
+}
+
+/*
+Draw the Electoral College map of the given computation as of the given date (DummyTime = now) and save it as SVG and PNG.
+
+Each state is filled per the ReportEC computation. Maine and Nebraska are filled per their statewide result;
+their districts count in the legend totals.
+*/
+func plotECMap(opts ecOptions, results []stateResult) {
+	glob := global.GetGlobalRef()
+	asOf := opts.asOf
+	recordForecast(asOf, opts, results)
+	totals := tallyEC(results)
+	byState := make(map[string]stateResult)
+	for _, result := range results {
+		byState[result.entry.Stcode] = result
+	}
+
+	plt := plot.New()
+	plt.HideAxes()
+	name := "ecmap"
+	if asOf == global.DummyTime {
+		plt.Title.Text = "Electoral College"
+	} else {
+		plt.Title.Text = fmt.Sprintf("Electoral College as of %s", asOf.Format("2006-01-02"))
+		if !strings.Contains(glob.PlotFileName, "{date}") {
+			name = fmt.Sprintf("ecmap_%s", asOf.Format("2006-01-02"))
+		}
+	}
+
+	// State polygons, and a label at the centre of each state's largest polygon.
+	type mapLabel struct {
+		centre   plotter.XY
+		vertices int
+	}
+	stateLabelMap := make(map[string]mapLabel)
+	var stcodes []string
+	for _, polygon := range mapPolygons() {
+		result, ok := byState[polygon.stcode]
+		if !ok {
+			continue
+		}
+		shape, err := plotter.NewPolygon(polygon.xys)
+		if err != nil {
+			log.Fatalf("plotECMap: plotter.NewPolygon(%s) failed, reason: %s\n", polygon.stcode, err.Error())
+		}
+		shape.Color = mapColour(result)
+		shape.LineStyle.Color = color.White
+		shape.LineStyle.Width = vg.Points(0.5)
+		plt.Add(shape)
+
+		previous, ok := stateLabelMap[polygon.stcode]
+		if !ok {
+			stcodes = append(stcodes, polygon.stcode)
+		} else if previous.vertices >= len(polygon.xys) {
+			continue
+		}
+		label := mapLabel{vertices: len(polygon.xys)}
+		for _, xy := range polygon.xys {
+			label.centre.X += xy.X / float64(len(polygon.xys))
+			label.centre.Y += xy.Y / float64(len(polygon.xys))
+		}
+		stateLabelMap[polygon.stcode] = label
+	}
+	var labels plotter.XYLabels
+	for _, stcode := range stcodes {
+		labels.XYs = append(labels.XYs, stateLabelMap[stcode].centre)
+		labels.Labels = append(labels.Labels, stcode)
+	}
+	stateLabels, err := plotter.NewLabels(labels)
+	if err != nil {
+		log.Fatalf("plotECMap: plotter.NewLabels failed, reason: %s\n", err.Error())
+	}
+	for ix := range stateLabels.TextStyle {
+		stateLabels.TextStyle[ix].XAlign = draw.XCenter
+		stateLabels.TextStyle[ix].YAlign = draw.YCenter
+		stateLabels.TextStyle[ix].Font.Size = vg.Points(6)
+	}
+	plt.Add(stateLabels)
+
+	// EV totals.
+	addMapLegend(plt, totals)
+
+	// Give the canvas the proportions of the map area so that the states keep their shape.
+	plt.X.Min, plt.X.Max = -23.5, 23.5
+	plt.Y.Min, plt.Y.Max = 22.5, 50.0
+	width := 2.0 * glob.PlotWidth
+	height := width * (plt.Y.Max - plt.Y.Min) / (plt.X.Max - plt.X.Min)
+	savePlot(plt, width, height, name, asOf, plotFormatsWith("svg", "png"))
+	log.Printf("EC map %s: Dem %d, Gop %d, Tossup %d\n", name, totals.demECV, totals.gopECV, totals.tossupECV)
+}
@@ -4,10 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"math"
 	"os"
 	"path/filepath"
 	"ppolls2024/global"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -52,7 +52,7 @@ func MakeDir(pathDir string) {
 	info, err := os.Stat(pathDir)
 	if err == nil { // found it
 		if !info.IsDir() { // expected a directory, not a simple file !!
-			log.Fatal("MakeDir: Observed a simple file: %s (expected a directory)\n", pathDir)
+			log.Fatalf("MakeDir: Observed a simple file: %s (expected a directory)\n", pathDir)
 		}
 	} else { // not found or an error occurred
 		if os.IsNotExist(err) {
@@ -114,129 +114,106 @@ func MonthToInt(monthStr string) (int, error) {
 	return -1, errors.New(errMsg)
 }
 
-// Given a state, return the ECV for that state.
-func StateToECV(state string) int {
-	arg := strings.ToUpper(state)
-	for ii := 0; ii < len(global.StateTable); ii++ {
-		if arg == global.StateTable[ii].Stcode {
-			return global.StateTable[ii].Votes
-		}
+/*
+NormalizeStcode - Upshift a state code and normalise congressional district codes to the state table form (E.g. ME-2).
+
+	Accepted district forms: ME2, ME-2, ME_2, ME-CD2, MECD2.
+*/
+func NormalizeStcode(stcode string) string {
+	code := strings.ToUpper(strings.TrimSpace(stcode))
+	if len(code) < 3 {
+		return code
 	}
-	log.Fatalf(fmt.Sprintf("StateToECV: invalid state code (%s)", state))
-	return -1 // dummy return
+	suffix := strings.TrimLeft(code[2:], "-_")
+	suffix = strings.TrimPrefix(suffix, "CD")
+	if _, err := strconv.Atoi(suffix); err != nil {
+		return code
+	}
+	return code[:2] + "-" + suffix
 }
 
-// Calculate not Dem nor Gop.
-func CalcOther(dem, gop float64) float64 {
-	return 100.0 - (dem + gop)
+// Given a state, return the ECV for that state.
+func StateToECV(state string) int {
+	return StateTableLookup(state).Votes
 }
 
-// Trend calculation.
-// If less than 3 points, return "--".
-// If rising, return "up".
-// If falling, return "dn".
-// Otherwise, return "--".
-func CalcTrend(array []float64) string {
-	num := len(array)
-	if num < 3 {
-		return "--"
-	}
-	if array[num-1] > array[num-2] {
-		if array[num-2] > array[num-3] {
-			return "u2"
-		}
-		return "u1"
+// Given a state, return a pointer to its state table entry.
+func StateTableLookup(state string) *global.StateTableEntry_t {
+	entry := findState(state)
+	if entry == nil {
+		log.Fatalf(fmt.Sprintf("StateTableLookup: invalid state code (%s)", state))
 	}
-	if array[num-1] < array[num-2] {
-		if array[num-2] < array[num-3] {
-			return "d2"
-		}
-		return "d1"
-	}
-	return "--"
+	return entry
 }
 
-// Translate true | false into "**" | "  ".
-func getFactorString(arg bool) string {
-	if arg {
-		return "**"
+// Given a state, return a pointer to its state table entry or nil if there is none.
+func findState(state string) *global.StateTableEntry_t {
+	arg := strings.ToUpper(state)
+	for ii := 0; ii < len(global.StateTable); ii++ {
+		if arg == global.StateTable[ii].Stcode {
+			return &global.StateTable[ii]
+		}
 	}
-	return "  "
+	return nil
 }
 
-/*
-ECVAward1 - ECV Award Algorithm 1.
-
-	Calculate the Other percentage = 100 - the sum of the candidate percentages.
-	Split the "Other" percentage proportionally amongst the candidates.
-	Calculate the difference = absolute value of the difference between the candidates.
-
-	If the difference is below the tossup threshold, then this state is a tossup.
-*/
-func ECVAward1(stateVotes int, pctDem, pctGop float64) (string, int, int, int) {
+// Given a user-defined group name (case-insensitive), return a pointer to the group or nil if there is none.
+func findGroup(name string) *global.Group_t {
 	glob := global.GetGlobalRef()
-	pctOther := CalcOther(pctDem, pctGop)
-
-	pctDem += pctOther * pctDem / 100.0
-	pctGop += pctOther * pctGop / 100.0
-	diff := math.Abs(pctDem - pctGop)
-
-	if diff < glob.TossupThreshold {
-		return "TOSSUP", 0, 0, stateVotes
-	} else {
-		if pctDem > pctGop {
-			return "Dem", stateVotes, 0, 0
-		} else {
-			return "Gop", 0, stateVotes, 0
+	for ii := range glob.Groups {
+		if strings.EqualFold(name, glob.Groups[ii].Name) {
+			return &glob.Groups[ii]
 		}
 	}
+	return nil
 }
 
-/*
-ECVAward2 - ECV Award Algorithm 2.
-
-	Calculate the Other percentage = 100 - the sum of the candidate percentages.
-	Calculate the difference = absolute value of the difference between the candidates.
-
-	If the "Other" percentage exceeds the difference, then flag this state on return.
-	If the difference is below the tossup threshold, then this state is a tossup.
-*/
-func ECVAward2(stateVotes int, pctDem, pctGop float64) (string, int, int, int, string) {
-	glob := global.GetGlobalRef()
-	diff := math.Abs(pctDem - pctGop)
-	pctOther := CalcOther(pctDem, pctGop)
-	if diff < glob.TossupThreshold {
-		return "TOSSUP", 0, 0, stateVotes, getFactorString(pctOther > diff)
-	}
-	if pctDem > pctGop {
-		return "Dem", stateVotes, 0, 0, getFactorString(pctOther > diff)
-	}
-	return "Gop", 0, stateVotes, 0, getFactorString(pctOther > diff)
+// Calculate not Dem nor Gop.
+func CalcOther(dem, gop float64) float64 {
+	return 100.0 - (dem + gop)
 }
 
 /*
-ECVAward3 - ECV Award Algorithm 3.
+ResolveStates - Translate a comma-separated list of state codes and/or group names into a list of state codes.
 
-	Calculate the Other percentage = 100 - the sum of the candidate percentages.
-	Calculate the difference = absolute value of the difference between the candidates.
+	BATTLEGROUND = the battleground states
+	STRONGLYDEM  = the strongly Democrat states
+	STRONGLYGOP  = the strongly GOP states
+	Any user-defined group name from the configuration file (case-insensitive).
 
-	If the "Other" percentage exceeds the difference, then this state is a tossup.
-	If the difference is below the tossup threshold, then this state is a tossup.
+An unknown state code or group name is fatal. A state named more than once is listed once.
 */
-func ECVAward3(stateVotes int, pctDem, pctGop float64) (string, int, int, int, string) {
+func ResolveStates(ids string) []string {
 	glob := global.GetGlobalRef()
-	diff := math.Abs(pctDem - pctGop)
-	pctOther := CalcOther(pctDem, pctGop)
-	if pctOther > diff {
-		return "TOSSUP", 0, 0, stateVotes, getFactorString(true)
-	}
-	if diff < glob.TossupThreshold {
-		return "TOSSUP", 0, 0, stateVotes, " "
+	var states []string
+	addStates := func(stcodes ...string) {
+		for _, stcode := range stcodes {
+			if !searchSlice(states, stcode) {
+				states = append(states, stcode)
+			}
+		}
 	}
-	if pctDem > pctGop {
-		return "Dem", stateVotes, 0, 0, " "
+	for _, id := range strings.Split(strings.ToUpper(ids), ",") {
+		id = NormalizeStcode(id)
+		switch id {
+		case "":
+			continue
+		case "BATTLEGROUND":
+			addStates(glob.Battleground...)
+		case "STRONGLYDEM":
+			addStates(glob.StronglyDem...)
+		case "STRONGLYGOP":
+			addStates(glob.StronglyGop...)
+		default:
+			if group := findGroup(id); group != nil {
+				addStates(group.States...)
+				continue
+			}
+			StateToECV(id) // validate the state code
+			addStates(id)
+		}
 	}
-	return "Gop", 0, stateVotes, 0, " "
+	return states
 }
 
 // searchSlice looks for a target string in an array of strings.
@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"ppolls2024/global"
+	"testing"
+	"time"
+)
+
+// Set the configuration parameters that the tested functions read, as in the shipped config.yaml.
+func setTestGlobals() *global.GlobalsStruct {
+	glob := global.GetGlobalRef()
+	glob.DbDriver = "sqlite"
+	glob.ConfidenceLevel = 0.95
+	glob.DefSampleSize = 600
+	glob.TrendHalfLife = 14.0
+	glob.TrendWindow = 42
+	glob.LoessSpan = 21
+	glob.KalmanDrift = 0.3
+	glob.BootstrapSamples = 1000
+	glob.BootstrapSeed = 2024
+	glob.HouseEffectWin = 14
+	glob.HouseEffectMin = 2
+	glob.DateThreshold = global.DummyTime
+	return glob
+}
+
+// Date of the given day of September 2024.
+func testDay(day int) time.Time {
+	return time.Date(2024, time.September, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestNormalizeStcode(t *testing.T) {
+	tests := []struct {
+		stcode string
+		want   string
+	}{
+		{"pa", "PA"},
+		{" dc ", "DC"},
+		{"ME-2", "ME-2"},
+		{"me2", "ME-2"},
+		{"ME_2", "ME-2"},
+		{"me-cd2", "ME-2"},
+		{"NECD3", "NE-3"},
+		{"battleground", "BATTLEGROUND"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeStcode(tt.stcode); got != tt.want {
+			t.Errorf("NormalizeStcode(%q) = %q, want %q", tt.stcode, got, tt.want)
+		}
+	}
+}
@@ -7,20 +7,53 @@ import (
 	"path/filepath"
 	"ppolls2024/global"
 	"ppolls2024/helpers"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Show help and then exit to the O/S
 func showHelp() {
 	suffix := filepath.Base(os.Args[0])
-	fmt.Printf("\nUsage:  %s  {-f  -l  -p  -r ID}\n\nwhere\n\n", suffix)
+	fmt.Printf("\nUsage:  %s  {-f  -l  -p  -r ID}  [options]\n\nwhere\n\n", suffix)
 	fmt.Printf("\t-f:\tFetch latest poll data from Internet --> directory csv\n")
 	fmt.Printf("\t-l:\tLoad poll data from directory csv\n")
 	fmt.Printf("\t-p:\tGenerate plots\n")
 	fmt.Printf("\t-r ID:\tReport by identifier (ID):\n")
 	fmt.Printf("\t\tSC\tSC = state code (E.g. AL).\n")
+	fmt.Printf("\t\tSC,SC,...\tSeveral state codes and/or group names (BATTLEGROUND, STRONGLYDEM, STRONGLYGOP,\n")
+	fmt.Printf("\t\t\t\tor a group defined in the configuration file).\n")
 	fmt.Printf("\t\tEC\tElectoral College tallies for all states.\n")
+	fmt.Printf("\t\tTP\tTipping-point analysis for both candidates.\n")
+	fmt.Printf("\t\tDIFF\tEC summary differences between two as-of dates.\n")
+	fmt.Printf("\t\tPV\tNational popular vote estimate weighted by expected turnout.\n")
+	fmt.Printf("\t\tHOUSE\tPollster house effects (average lean).\n")
+	fmt.Printf("\t\tGROUPS\tEV subtotals and average margin per state group.\n")
+	fmt.Printf("\t\tALGS\tSide-by-side comparison of all ECV award algorithms.\n")
+	fmt.Printf("\t\tPOLLSTERS\tInventory of every pollster: polls, states, date range, margin.\n")
+	fmt.Printf("\t\tCOVERAGE\tPolls since DateThreshold and poll age per state; thin battlegrounds flagged.\n")
+	fmt.Printf("\t\tBACKTEST\tScore the algorithms against the BacktestCycle certified results.\n")
 	fmt.Printf("\t-b:\tProcess only battleground states in -r ec\n")
+	fmt.Printf("\t-g IDS:\tProcess only these comma-separated state codes and/or group names in -r ec, -r algs, -r coverage, and -p\n")
+	fmt.Printf("\t-s FILE:\tCompare -r ec with the what-if scenario in YAML file FILE\n")
+	fmt.Printf("\t--cycle YYYY:\tFetch, load, plot, and report the polls of election year YYYY (default: %d)\n", global.CURRENT_CYCLE)
+	fmt.Printf("\t--map-dates DATES:\tWith -p, also draw the EC map as of each comma-separated YYYY-MM-DD date\n")
+	fmt.Printf("\t--plot-formats LIST:\tWith -p, save plots in these comma-separated formats: %s (replaces PlotFormats)\n",
+		strings.Join(helpers.PlotFormatNames, ", "))
+	fmt.Printf("\t--list-algorithms:\tList the ECV award algorithms and exit\n")
+	fmt.Printf("\t--house-adjust:\tSubtract pollster house effects before averaging (EC-based reports)\n")
+	fmt.Printf("\nState report (-r SC) options:\n\n")
+	fmt.Printf("\t--from YYYY-MM-DD\tOnly polls ending on or after this date (replaces DateThreshold)\n")
+	fmt.Printf("\t--to YYYY-MM-DD\t\tOnly polls ending on or before this date\n")
+	fmt.Printf("\t--pollster TEXT\t\tOnly pollsters whose name contains TEXT (case-insensitive)\n")
+	fmt.Printf("\t--min-sample N\t\tOnly polls with a reported sample size of at least N\n")
+	fmt.Printf("\t--all\t\t\tShow all matching polls (ignore PollHistoryLimit)\n")
+	fmt.Printf("\nDiff report (-r diff) options:\n\n")
+	fmt.Printf("\t--date1 YYYY-MM-DD\tFirst as-of date (default: yesterday)\n")
+	fmt.Printf("\t--date2 YYYY-MM-DD\tSecond as-of date (default: today)\n")
+	fmt.Printf("\t--by-load\t\tAs-of dates refer to when polls were loaded, not when they ended\n")
+	fmt.Printf("\nPollster report (-r pollsters) options:\n\n")
+	fmt.Printf("\t--sort KEY\t\tSort by %s (default: polls)\n", strings.Join(helpers.PollsterSortKeys, ", "))
 	fmt.Printf("\nExit codes:\n")
 	fmt.Printf("\t0\tNormal completion or help shown due to command line error.\n")
 	fmt.Printf("\t1\tSomething went wrong during execution.\n\n")
@@ -32,6 +65,10 @@ func main() {
 
 	var params []string
 	rpt := ""
+	groupIds := ""
+	cycle := 0
+	plotFormats := false
+	scFilters := false
 	glob := global.InitGlobals()
 	helpers.GetConfig()
 
@@ -44,6 +81,26 @@ func main() {
 		showHelp()
 	}
 
+	// Get the value that follows a parameter.
+	getValue := func(ii int) string {
+		if ii+1 >= len(params) {
+			fmt.Printf("*** The %s parameter lacks a value!\n", params[ii])
+			showHelp()
+		}
+		return params[ii+1]
+	}
+
+	// Get the date value that follows a parameter.
+	getDate := func(ii int) time.Time {
+		value := getValue(ii)
+		tm, err := helpers.YYYY_MM_DDtoTime(value)
+		if err != nil {
+			fmt.Printf("*** The %s parameter value (%s) is not a valid YYYY-MM-DD date!\n", params[ii], value)
+			showHelp()
+		}
+		return tm
+	}
+
 	for ii := 0; ii < len(params); ii++ {
 		switch params[ii] {
 		case "-h":
@@ -64,12 +121,114 @@ func main() {
 			glob.FlagReport = true
 		case "-b":
 			glob.FlagBattleground = true
+		case "-g":
+			groupIds = getValue(ii)
+			ii++
+		case "-s":
+			glob.ScenarioFile = getValue(ii)
+			ii++
+		case "--from":
+			glob.FilterFrom = getDate(ii)
+			scFilters = true
+			ii++
+		case "--to":
+			glob.FilterTo = getDate(ii)
+			scFilters = true
+			ii++
+		case "--pollster":
+			glob.FilterPollster = getValue(ii)
+			scFilters = true
+			ii++
+		case "--min-sample":
+			value := getValue(ii)
+			minSample, err := strconv.Atoi(value)
+			if err != nil || minSample < 0 {
+				fmt.Printf("*** The --min-sample parameter value (%s) is not a valid non-negative integer!\n", value)
+				showHelp()
+			}
+			glob.FilterMinSample = minSample
+			scFilters = true
+			ii++
+		case "--all":
+			glob.FlagAll = true
+			scFilters = true
+		case "--date1":
+			glob.DiffDate1 = getDate(ii)
+			ii++
+		case "--date2":
+			glob.DiffDate2 = getDate(ii)
+			ii++
+		case "--by-load":
+			glob.FlagByLoad = true
+		case "--house-adjust":
+			glob.FlagHouseAdjust = true
+		case "--cycle":
+			value := getValue(ii)
+			year, err := strconv.Atoi(value)
+			if err != nil || year < 1900 || year > global.CURRENT_CYCLE {
+				fmt.Printf("*** The --cycle parameter value (%s) is not a valid election year!\n", value)
+				showHelp()
+			}
+			cycle = year
+			ii++
+		case "--sort":
+			value := strings.ToLower(getValue(ii))
+			valid := false
+			for _, key := range helpers.PollsterSortKeys {
+				if value == key {
+					valid = true
+				}
+			}
+			if !valid {
+				fmt.Printf("*** The --sort parameter value (%s) is not one of: %s!\n", value, strings.Join(helpers.PollsterSortKeys, ", "))
+				showHelp()
+			}
+			glob.PollsterSort = value
+			ii++
+		case "--map-dates":
+			for _, value := range strings.Split(getValue(ii), ",") {
+				tm, err := helpers.YYYY_MM_DDtoTime(strings.TrimSpace(value))
+				if err != nil {
+					fmt.Printf("*** The --map-dates parameter value (%s) is not a valid YYYY-MM-DD date!\n", value)
+					showHelp()
+				}
+				glob.MapDates = append(glob.MapDates, tm)
+			}
+			ii++
+		case "--plot-formats":
+			formats, err := helpers.ParsePlotFormats(getValue(ii))
+			if err != nil {
+				fmt.Printf("*** The --plot-formats parameter: %s!\n", err.Error())
+				showHelp()
+			}
+			glob.PlotFormats = formats
+			plotFormats = true
+			ii++
+		case "--list-algorithms":
+			helpers.ListAlgorithms()
 		default:
 			fmt.Printf("*** The specified parameter (%s) is not supported!\n", params[ii])
 			showHelp()
 		}
 	}
 
+	// Select the election cycle. The backtest defaults to the configured prior cycle.
+	if cycle == 0 && rpt == "BACKTEST" {
+		cycle = glob.BacktestCycle
+	}
+	if cycle != 0 {
+		global.SetCycle(cycle)
+		log.Printf("Election cycle: %d, database: %s\n", glob.Cycle, glob.DbFile)
+	}
+
+	// Resolve the group filter.
+	if groupIds != "" {
+		glob.GroupFilter = helpers.ResolveStates(groupIds)
+		if rpt != "EC" && rpt != "ALGS" && rpt != "COVERAGE" && !glob.FlagPlot {
+			log.Println("Warning: No -r ec, -r algs, or -r coverage report nor plots requested. The group flag (-g) is ignored")
+		}
+	}
+
 	// If plotting, delete old plots.
 	if glob.FlagPlot {
 		err := os.RemoveAll(glob.DirPlots)
@@ -89,6 +248,33 @@ func main() {
 		log.Println("Warning: No reports requested. The battleground flag (-b) is ignored")
 	}
 
+	// Validate the use of -s.
+	if glob.ScenarioFile != "" && rpt != "EC" {
+		log.Println("Warning: No -r ec report requested. The scenario flag (-s) is ignored")
+	}
+
+	// Validate the use of the state report options.
+	namedReports := map[string]bool{"EC": true, "TP": true, "DIFF": true, "PV": true, "HOUSE": true, "GROUPS": true,
+		"ALGS": true, "COVERAGE": true, "POLLSTERS": true, "BACKTEST": true}
+	if scFilters && (!glob.FlagReport || namedReports[rpt]) {
+		log.Println("Warning: No -r SC report requested. The state report flags (--from, --to, --pollster, --min-sample, --all) are ignored")
+	}
+
+	// Validate the use of --sort.
+	if glob.PollsterSort != "polls" && rpt != "POLLSTERS" {
+		log.Println("Warning: No -r pollsters report requested. The sort flag (--sort) is ignored")
+	}
+
+	// Validate the use of --map-dates.
+	if glob.MapDates != nil && !glob.FlagPlot {
+		log.Println("Warning: No plots requested. The map dates flag (--map-dates) is ignored")
+	}
+
+	// Validate the use of --plot-formats.
+	if plotFormats && !glob.FlagPlot {
+		log.Println("Warning: No plots requested. The plot formats flag (--plot-formats) is ignored")
+	}
+
 	// Fetch new data?
 	if glob.FlagFetch {
 		if !helpers.Fetch(glob.DirCsv, glob.LocalCsvFile, glob.InternetCsvFile, glob.DirTemp) {
@@ -113,9 +299,28 @@ func main() {
 	// Run a report?
 	if glob.FlagReport {
 		helpers.DBOpen(glob.DbDriver, glob.DirDatabase, glob.DbFile)
-		if rpt == "EC" {
+		switch rpt {
+		case "EC":
 			helpers.ReportEC()
-		} else {
+		case "TP":
+			helpers.ReportTP()
+		case "DIFF":
+			helpers.ReportDiff()
+		case "PV":
+			helpers.ReportPV()
+		case "HOUSE":
+			helpers.ReportHouse()
+		case "GROUPS":
+			helpers.ReportGroups()
+		case "ALGS":
+			helpers.ReportAlgorithms()
+		case "COVERAGE":
+			helpers.ReportCoverage()
+		case "POLLSTERS":
+			helpers.ReportPollsters()
+		case "BACKTEST":
+			helpers.ReportBacktest()
+		default:
 			helpers.ReportSC(rpt)
 		}
 		helpers.DBClose()